@@ -0,0 +1,177 @@
+package findprocess
+
+import (
+	"sync"
+	"time"
+)
+
+// Alert is a condition worth notifying someone about
+type Alert struct {
+	// Name is the kind of alert, such as "fork-rate" or "oom-forecast"
+	Name string
+	// Identity names what the alert is about, such as a process identity or user. Alerts
+	// with the same Name and Identity are grouped.
+	Identity string
+	// Labels are matched by silences and maintenance windows, along with the implicit
+	// "alertname" and "identity" labels
+	Labels  map[string]string
+	Message string
+	Time    time.Time
+}
+
+// Notification is an alert that passed the AlertManager
+type Notification struct {
+	Alert
+	// Count is the number of times the alert fired since its last notification, including this one
+	Count int
+}
+
+// Silence suppresses alerts whose labels match all of Matchers until it expires
+type Silence struct {
+	Matchers map[string]string
+	Until    time.Time
+}
+
+// MaintenanceWindow suppresses alerts whose labels match all of Matchers for Duration
+// from Start, repeating every Every if it is set, such as 24 hours for a nightly window
+type MaintenanceWindow struct {
+	Matchers map[string]string
+	Start    time.Time
+	Duration time.Duration
+	Every    time.Duration
+}
+
+// active reports whether the window covers t
+func (w MaintenanceWindow) active(t time.Time) bool {
+	if t.Before(w.Start) {
+		return false
+	}
+	elapsed := t.Sub(w.Start)
+	if w.Every > 0 {
+		elapsed %= w.Every
+	}
+	return elapsed < w.Duration
+}
+
+// AlertManager groups repeated alerts, limits how often each is notified and drops
+// alerts that are silenced or inside a maintenance window. It is safe for concurrent use.
+type AlertManager struct {
+	repeatInterval time.Duration
+	notify         func(Notification)
+	now            func() time.Time
+
+	mu       sync.Mutex
+	groups   map[alertKey]*alertGroup
+	silences []Silence
+	windows  []MaintenanceWindow
+}
+
+type alertKey struct {
+	name     string
+	identity string
+}
+
+// alertGroup tracks the repeats of one alert since it was last notified
+type alertGroup struct {
+	notified time.Time
+	count    int
+}
+
+// NewAlertManager creates an AlertManager that calls notify for the first firing of an
+// alert and then at most once per repeatInterval while it keeps firing
+func NewAlertManager(repeatInterval time.Duration, notify func(Notification)) *AlertManager {
+	return &AlertManager{
+		repeatInterval: repeatInterval,
+		notify:         notify,
+		now:            time.Now,
+		groups:         make(map[alertKey]*alertGroup),
+	}
+}
+
+// Fire passes an alert through the manager, notifying it unless it is suppressed
+func (m *AlertManager) Fire(a Alert) {
+	if a.Time.IsZero() {
+		a.Time = m.now()
+	}
+
+	m.mu.Lock()
+	if m.suppressed(a) {
+		m.mu.Unlock()
+		return
+	}
+
+	key := alertKey{a.Name, a.Identity}
+	g, ok := m.groups[key]
+	if !ok {
+		g = &alertGroup{}
+		m.groups[key] = g
+	}
+	g.count++
+	if !g.notified.IsZero() && a.Time.Sub(g.notified) < m.repeatInterval {
+		m.mu.Unlock()
+		return
+	}
+
+	n := Notification{Alert: a, Count: g.count}
+	g.notified, g.count = a.Time, 0
+	m.mu.Unlock()
+
+	m.notify(n)
+}
+
+// Silence suppresses alerts matching all of matchers for d
+func (m *AlertManager) Silence(matchers map[string]string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.silences = append(m.silences, Silence{Matchers: matchers, Until: m.now().Add(d)})
+}
+
+// AddMaintenanceWindow suppresses alerts matching the window's matchers while it is active
+func (m *AlertManager) AddMaintenanceWindow(w MaintenanceWindow) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.windows = append(m.windows, w)
+}
+
+// suppressed reports whether an alert is silenced or in a maintenance window, dropping
+// expired silences. m.mu must be held.
+func (m *AlertManager) suppressed(a Alert) bool {
+	active := m.silences[:0]
+	matched := false
+	for _, s := range m.silences {
+		if !a.Time.Before(s.Until) {
+			continue
+		}
+		active = append(active, s)
+		matched = matched || alertMatches(a, s.Matchers)
+	}
+	m.silences = active
+	if matched {
+		return true
+	}
+
+	for _, w := range m.windows {
+		if w.active(a.Time) && alertMatches(a, w.Matchers) {
+			return true
+		}
+	}
+	return false
+}
+
+func alertMatches(a Alert, matchers map[string]string) bool {
+	for k, v := range matchers {
+		var actual string
+		switch k {
+		case "alertname":
+			actual = a.Name
+		case "identity":
+			actual = a.Identity
+		default:
+			actual = a.Labels[k]
+		}
+		if actual != v {
+			return false
+		}
+	}
+	return true
+}
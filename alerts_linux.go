@@ -0,0 +1,50 @@
+package findprocess
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Alert converts the event for an AlertManager. Rate spikes are grouped system-wide
+// and limit warnings per user.
+func (e ForkEvent) Alert() Alert {
+	if e.Kind == UserNearLimit && e.User != nil {
+		return Alert{
+			Name:     "user-process-limit",
+			Identity: e.User.User,
+			Labels:   map[string]string{"user": e.User.User},
+			Message:  fmt.Sprintf("user %s has %d threads of a %d limit", e.User.User, e.User.Threads, e.User.Limit),
+			Time:     e.Time,
+		}
+	}
+
+	a := Alert{
+		Name:     "fork-rate",
+		Identity: "system",
+		Labels:   map[string]string{},
+		Message:  fmt.Sprintf("%.0f forks per second", e.ForkRate),
+		Time:     e.Time,
+	}
+	if len(e.Parents) > 0 {
+		a.Labels["parent"] = e.Parents[0].Name
+		a.Message += fmt.Sprintf(", mostly from %s (%d)", e.Parents[0].Name, e.Parents[0].ID)
+	}
+	return a
+}
+
+// Alert converts the forecast for an AlertManager. Forecasts are grouped by process
+// identity so restarts of the same service share an alert while different services
+// with the same name do not.
+func (f MemoryForecast) Alert() Alert {
+	a := Alert{
+		Name:     "oom-forecast",
+		Identity: f.Identity,
+		Labels:   map[string]string{"name": f.Name, "pid": strconv.Itoa(f.ID)},
+		Message:  fmt.Sprintf("%s (%d) is forecast to run out of memory in %s", f.Name, f.ID, f.TimeToOOM),
+		Time:     f.Time,
+	}
+	if f.OOMKills > 0 {
+		a.Message = fmt.Sprintf("%s (%d): the OOM killer fired %d times in its cgroup", f.Name, f.ID, f.OOMKills)
+	}
+	return a
+}
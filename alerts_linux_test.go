@@ -0,0 +1,15 @@
+package findprocess
+
+import "testing"
+
+func TestMemoryForecastAlertIdentity(t *testing.T) {
+	a := MemoryForecast{Name: "python3", ID: 42, Identity: processIdentity("python3", []string{"python3", "worker.py"})}.Alert()
+	b := MemoryForecast{Name: "python3", ID: 43, Identity: processIdentity("python3", []string{"python3", "web.py"})}.Alert()
+	if a.Identity == b.Identity {
+		t.Errorf("different services with the same name share identity %q", a.Identity)
+	}
+	restarted := MemoryForecast{Name: "python3", ID: 99, Identity: processIdentity("python3", []string{"python3", "worker.py"})}.Alert()
+	if restarted.Identity != a.Identity {
+		t.Errorf("restarted service identity = %q, want %q", restarted.Identity, a.Identity)
+	}
+}
@@ -0,0 +1,107 @@
+package findprocess
+
+import (
+	"testing"
+	"time"
+)
+
+// testAlertManager returns an AlertManager whose clock is *now and the notifications it sent
+func testAlertManager(repeat time.Duration, now *time.Time) (*AlertManager, *[]Notification) {
+	var sent []Notification
+	m := NewAlertManager(repeat, func(n Notification) { sent = append(sent, n) })
+	m.now = func() time.Time { return *now }
+	return m, &sent
+}
+
+func TestAlertManagerGroupsRepeats(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m, sent := testAlertManager(time.Minute, &now)
+
+	for i := 0; i < 3; i++ {
+		m.Fire(Alert{Name: "oom-forecast", Identity: "java-1234abcd"})
+		now = now.Add(10 * time.Second)
+	}
+	// a different identity is its own group
+	m.Fire(Alert{Name: "oom-forecast", Identity: "java-5678ef00"})
+	if len(*sent) != 2 {
+		t.Fatalf("got %d notifications, want 2", len(*sent))
+	}
+	if n := (*sent)[0]; n.Identity != "java-1234abcd" || n.Count != 1 {
+		t.Errorf("first notification = %q count %d, want java-1234abcd count 1", n.Identity, n.Count)
+	}
+
+	now = now.Add(time.Minute)
+	m.Fire(Alert{Name: "oom-forecast", Identity: "java-1234abcd"})
+	if len(*sent) != 3 {
+		t.Fatalf("got %d notifications after the repeat interval, want 3", len(*sent))
+	}
+	// the two suppressed repeats and this one
+	if n := (*sent)[2]; n.Count != 3 {
+		t.Errorf("repeat notification count = %d, want 3", n.Count)
+	}
+}
+
+func TestAlertManagerSilence(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m, sent := testAlertManager(0, &now)
+
+	m.Silence(map[string]string{"alertname": "fork-rate", "parent": "make"}, time.Hour)
+	m.Fire(Alert{Name: "fork-rate", Identity: "system", Labels: map[string]string{"parent": "make"}})
+	if len(*sent) != 0 {
+		t.Fatalf("silenced alert was notified")
+	}
+	m.Fire(Alert{Name: "fork-rate", Identity: "system", Labels: map[string]string{"parent": "bash"}})
+	if len(*sent) != 1 {
+		t.Fatalf("alert not matching the silence was not notified")
+	}
+
+	now = now.Add(time.Hour)
+	m.Fire(Alert{Name: "fork-rate", Identity: "system", Labels: map[string]string{"parent": "make"}})
+	if len(*sent) != 2 {
+		t.Fatalf("alert was still silenced after the silence expired")
+	}
+	if len(m.silences) != 0 {
+		t.Errorf("expired silence was not dropped")
+	}
+}
+
+func TestAlertManagerRecurringMaintenanceWindow(t *testing.T) {
+	start := time.Date(2024, 1, 1, 2, 0, 0, 0, time.UTC)
+	now := start.Add(-time.Minute)
+	m, sent := testAlertManager(0, &now)
+	m.AddMaintenanceWindow(MaintenanceWindow{
+		Matchers: map[string]string{"identity": "backup-0badf00d"},
+		Start:    start,
+		Duration: 30 * time.Minute,
+		Every:    24 * time.Hour,
+	})
+
+	tests := []struct {
+		at     time.Time
+		notify bool
+	}{
+		{start.Add(-time.Minute), true},
+		{start, false},
+		{start.Add(29 * time.Minute), false},
+		{start.Add(30 * time.Minute), true},
+		{start.Add(24*time.Hour + 10*time.Minute), false},
+		{start.Add(48*time.Hour - time.Minute), true},
+		{start.Add(72 * time.Hour), false},
+	}
+	for _, tt := range tests {
+		now = tt.at
+		before := len(*sent)
+		m.Fire(Alert{Name: "oom-forecast", Identity: "backup-0badf00d"})
+		if notified := len(*sent) > before; notified != tt.notify {
+			t.Errorf("at %s notified = %v, want %v", tt.at, notified, tt.notify)
+		}
+	}
+
+	// other identities are not covered by the window
+	now = start
+	before := len(*sent)
+	m.Fire(Alert{Name: "oom-forecast", Identity: "postgres-12345678"})
+	if len(*sent) != before+1 {
+		t.Errorf("alert outside the window's matchers was suppressed")
+	}
+}
@@ -0,0 +1,225 @@
+package findprocess
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// cgroupRoot is the mount point of the cgroup filesystems
+const cgroupRoot = "/sys/fs/cgroup"
+
+// cgroupV1Unlimited is the smallest value cgroup v1 uses to mean "no limit"; the kernel
+// reports unlimited memory as the largest page-aligned int64
+const cgroupV1Unlimited = 1 << 62
+
+// cgroupV2Dir returns the directory of a process's cgroup in the unified hierarchy, or
+// an empty string if there is no unified hierarchy
+func cgroupV2Dir(cgroups map[string]string) string {
+	p, ok := cgroups[""]
+	if !ok {
+		return ""
+	}
+	// hybrid hosts mount the unified hierarchy beneath the v1 controllers
+	for _, root := range []string{cgroupRoot, filepath.Join(cgroupRoot, "unified")} {
+		if _, err := os.Stat(filepath.Join(root, "cgroup.controllers")); err == nil {
+			return filepath.Join(root, p)
+		}
+	}
+	return ""
+}
+
+// cgroupV1Dir returns the directory of a process's cgroup in the v1 hierarchy of a controller
+func cgroupV1Dir(cgroups map[string]string, controller string) string {
+	for key, p := range cgroups {
+		for _, c := range strings.Split(key, ",") {
+			if c != controller {
+				continue
+			}
+			for _, mount := range []string{key, controller} {
+				if _, err := os.Stat(filepath.Join(cgroupRoot, mount)); err == nil {
+					return filepath.Join(cgroupRoot, mount, p)
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// lowestCgroupValue reads file in dir and each of its ancestors, returning the lowest value
+// parse accepts. Limits are inherited, so the lowest one up the tree is the one enforced.
+func lowestCgroupValue(dir, file string, parse func(string) (float64, bool)) (float64, bool) {
+	var lowest float64
+	found := false
+	for ; strings.HasPrefix(dir, cgroupRoot+"/"); dir = filepath.Dir(dir) {
+		data, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			continue
+		}
+		if v, ok := parse(strings.TrimSpace(string(data))); ok && (!found || v < lowest) {
+			lowest, found = v, true
+		}
+	}
+	return lowest, found
+}
+
+// cgroupMemoryLimit returns the memory limit in bytes of a process's cgroup, or 0 if it is unlimited
+func cgroupMemoryLimit(pID int) uint64 {
+	cgroups, err := readCgroups(pID)
+	if err != nil {
+		return 0
+	}
+
+	parse := func(s string) (float64, bool) {
+		n, err := strconv.ParseUint(s, 10, 64)
+		return float64(n), err == nil && n < cgroupV1Unlimited
+	}
+	if dir := cgroupV2Dir(cgroups); dir != "" {
+		if v, ok := lowestCgroupValue(dir, "memory.max", parse); ok {
+			return uint64(v)
+		}
+	}
+	if dir := cgroupV1Dir(cgroups, "memory"); dir != "" {
+		if v, ok := lowestCgroupValue(dir, "memory.limit_in_bytes", parse); ok {
+			return uint64(v)
+		}
+	}
+	return 0
+}
+
+// cgroupCPULimit returns the CPU quota of a process's cgroup as a number of CPUs, or 0 if it is unlimited
+func cgroupCPULimit(pID int) float64 {
+	cgroups, err := readCgroups(pID)
+	if err != nil {
+		return 0
+	}
+
+	if dir := cgroupV2Dir(cgroups); dir != "" {
+		// cpu.max is "$QUOTA $PERIOD", where the quota may be "max"
+		v, ok := lowestCgroupValue(dir, "cpu.max", func(s string) (float64, bool) {
+			fields := strings.Fields(s)
+			if len(fields) != 2 {
+				return 0, false
+			}
+			return cpuQuota(fields[0], fields[1])
+		})
+		if ok {
+			return v
+		}
+	}
+	if dir := cgroupV1Dir(cgroups, "cpu"); dir != "" {
+		// v1 splits the quota and period into two files, so they are paired per directory
+		var lowest float64
+		for d := dir; strings.HasPrefix(d, cgroupRoot+"/"); d = filepath.Dir(d) {
+			quota, err1 := os.ReadFile(filepath.Join(d, "cpu.cfs_quota_us"))
+			period, err2 := os.ReadFile(filepath.Join(d, "cpu.cfs_period_us"))
+			if err1 != nil || err2 != nil {
+				continue
+			}
+			if cpus, ok := cpuQuota(strings.TrimSpace(string(quota)), strings.TrimSpace(string(period))); ok && (lowest == 0 || cpus < lowest) {
+				lowest = cpus
+			}
+		}
+		return lowest
+	}
+	return 0
+}
+
+// cpuQuota converts a CFS quota and period to a number of CPUs
+func cpuQuota(quota, period string) (float64, bool) {
+	q, err := strconv.ParseFloat(quota, 64)
+	if err != nil || q <= 0 {
+		return 0, false
+	}
+	p, err := strconv.ParseFloat(period, 64)
+	if err != nil || p <= 0 {
+		return 0, false
+	}
+	return q / p, true
+}
+
+// cgroupMemoryHeadroom returns how many more bytes a process's cgroup can use before it
+// reaches the tightest memory limit between it and the root. It returns false if no
+// ancestor has a limit.
+func cgroupMemoryHeadroom(pID int) (uint64, bool) {
+	cgroups, err := readCgroups(pID)
+	if err != nil {
+		return 0, false
+	}
+
+	limitFile, usageFile := "memory.max", "memory.current"
+	dir := cgroupV2Dir(cgroups)
+	if _, err := os.Stat(filepath.Join(dir, limitFile)); dir == "" || err != nil {
+		limitFile, usageFile = "memory.limit_in_bytes", "memory.usage_in_bytes"
+		if dir = cgroupV1Dir(cgroups, "memory"); dir == "" {
+			return 0, false
+		}
+	}
+
+	var headroom uint64
+	found := false
+	for ; strings.HasPrefix(dir, cgroupRoot+"/"); dir = filepath.Dir(dir) {
+		limit, err1 := readCgroupUint(filepath.Join(dir, limitFile))
+		usage, err2 := readCgroupUint(filepath.Join(dir, usageFile))
+		if err1 != nil || err2 != nil || limit >= cgroupV1Unlimited {
+			continue
+		}
+		h := uint64(0)
+		if limit > usage {
+			h = limit - usage
+		}
+		if !found || h < headroom {
+			headroom, found = h, true
+		}
+	}
+	return headroom, found
+}
+
+// cgroupOOMKills returns the number of processes the OOM killer has killed in a process's cgroup
+func cgroupOOMKills(pID int) uint64 {
+	cgroups, err := readCgroups(pID)
+	if err != nil {
+		return 0
+	}
+
+	if dir := cgroupV2Dir(cgroups); dir != "" {
+		if events, err := readFlatKeyed(filepath.Join(dir, "memory.events")); err == nil {
+			return events["oom_kill"]
+		}
+	}
+	if dir := cgroupV1Dir(cgroups, "memory"); dir != "" {
+		if control, err := readFlatKeyed(filepath.Join(dir, "memory.oom_control")); err == nil {
+			return control["oom_kill"]
+		}
+	}
+	return 0
+}
+
+func readCgroupUint(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// readFlatKeyed parses a cgroup file of "key value" lines, such as memory.events
+func readFlatKeyed(path string) (map[string]uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]uint64)
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if n, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			values[fields[0]] = n
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,230 @@
+package findprocess
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ChargeBy selects how a chargeback report groups processes
+type ChargeBy int
+
+const (
+	// ChargeByUser groups processes by their real user
+	ChargeByUser ChargeBy = iota
+	// ChargeByCgroup groups processes by their cgroup path
+	ChargeByCgroup
+	// ChargeByUnit groups processes by their systemd service unit
+	ChargeByUnit
+)
+
+// ParseChargeBy converts "user", "cgroup" or "unit" to a ChargeBy
+func ParseChargeBy(s string) (ChargeBy, error) {
+	switch s {
+	case "user":
+		return ChargeByUser, nil
+	case "cgroup":
+		return ChargeByCgroup, nil
+	case "unit":
+		return ChargeByUnit, nil
+	}
+	return 0, fmt.Errorf("findprocess: unknown chargeback grouping %q", s)
+}
+
+// ChargebackConfig configures a chargeback report
+type ChargebackConfig struct {
+	By ChargeBy
+	// From and To limit the report to samples taken at or after From and before To;
+	// zero values leave the range open
+	From time.Time
+	To   time.Time
+}
+
+// Charge is the resource usage of one group of processes
+type Charge struct {
+	// Key is the user, cgroup or unit, or "(none)" for processes outside any unit
+	Key        string  `json:"key"`
+	CPUSeconds float64 `json:"cpu_seconds"`
+	// MemoryGBHours is PSS, or RSS where PSS was unreadable, in GiB multiplied by hours
+	MemoryGBHours float64 `json:"memory_gb_hours"`
+	ReadBytes     uint64  `json:"read_bytes"`
+	WriteBytes    uint64  `json:"write_bytes"`
+}
+
+// Chargeback totals the usage in recorded samples by group. Each sample's rates cover
+// the time since the previous sample of the same process, so that time is charged at
+// those rates; the first sample of a process only marks where its series begins.
+func Chargeback(samples []Sample, cfg ChargebackConfig) []Charge {
+	sorted := append([]Sample(nil), samples...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Time.Before(sorted[j].Time) })
+
+	type series struct {
+		pID      int
+		identity string
+	}
+	previous := make(map[series]time.Time)
+	charges := make(map[string]*Charge)
+
+	for _, s := range sorted {
+		key := series{s.PID, s.Identity}
+		prev, ok := previous[key]
+		previous[key] = s.Time
+		if !ok || (!cfg.From.IsZero() && s.Time.Before(cfg.From)) || (!cfg.To.IsZero() && !s.Time.Before(cfg.To)) {
+			continue
+		}
+		elapsed := s.Time.Sub(prev).Seconds()
+
+		group := chargeKey(s, cfg.By)
+		c, ok := charges[group]
+		if !ok {
+			c = &Charge{Key: group}
+			charges[group] = c
+		}
+
+		memory := s.PSS
+		if memory == 0 {
+			memory = s.RSS
+		}
+		c.CPUSeconds += s.CPUPercent / 100 * elapsed
+		c.MemoryGBHours += float64(memory) / (1 << 30) * elapsed / 3600
+		c.ReadBytes += uint64(s.ReadBytesPerSec * elapsed)
+		c.WriteBytes += uint64(s.WriteBytesPerSec * elapsed)
+	}
+
+	results := make([]Charge, 0, len(charges))
+	for _, c := range charges {
+		results = append(results, *c)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Key < results[j].Key })
+	return results
+}
+
+func chargeKey(s Sample, by ChargeBy) string {
+	var key string
+	switch by {
+	case ChargeByUser:
+		key = s.User
+	case ChargeByCgroup:
+		key = s.Cgroup
+	case ChargeByUnit:
+		key = s.Unit
+	}
+	if key == "" {
+		return "(none)"
+	}
+	return key
+}
+
+// WriteChargesCSV writes a chargeback report as CSV with a header row
+func WriteChargesCSV(w io.Writer, charges []Charge) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"key", "cpu_seconds", "memory_gb_hours", "read_bytes", "write_bytes"})
+	for _, c := range charges {
+		cw.Write([]string{
+			c.Key,
+			strconv.FormatFloat(c.CPUSeconds, 'f', 2, 64),
+			strconv.FormatFloat(c.MemoryGBHours, 'f', 4, 64),
+			strconv.FormatUint(c.ReadBytes, 10),
+			strconv.FormatUint(c.WriteBytes, 10),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteChargesJSON writes a chargeback report as a JSON array
+func WriteChargesJSON(w io.Writer, charges []Charge) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(charges)
+}
+
+// ReadSamples reads samples written by a CSVSampleWriter or a JSONSampleWriter. JSON
+// lines are recognised by their leading brace; anything else is read as CSV.
+func ReadSamples(r io.Reader) ([]Sample, error) {
+	br := bufio.NewReader(r)
+	first, err := br.Peek(1)
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if first[0] == '{' {
+		return readJSONSamples(br)
+	}
+	return readCSVSamples(br)
+}
+
+func readJSONSamples(r io.Reader) ([]Sample, error) {
+	var samples []Sample
+	dec := json.NewDecoder(r)
+	for {
+		var s Sample
+		if err := dec.Decode(&s); err == io.EOF {
+			return samples, nil
+		} else if err != nil {
+			return nil, err
+		}
+		samples = append(samples, s)
+	}
+}
+
+// readCSVSamples finds columns by the header row, so files written before a column was
+// added can still be read
+func readCSVSamples(r io.Reader) ([]Sample, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	columns := make(map[string]int, len(records[0]))
+	for i, name := range records[0] {
+		columns[name] = i
+	}
+
+	samples := make([]Sample, 0, len(records)-1)
+	for line, record := range records[1:] {
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return record[i]
+			}
+			return ""
+		}
+		number := func(name string) float64 {
+			f, _ := strconv.ParseFloat(field(name), 64)
+			return f
+		}
+
+		t, err := time.Parse(time.RFC3339Nano, field("time"))
+		if err != nil {
+			return nil, fmt.Errorf("findprocess: line %d: %v", line+2, err)
+		}
+		samples = append(samples, Sample{
+			Time:             t,
+			Identity:         field("identity"),
+			PID:              int(number("pid")),
+			Name:             field("name"),
+			User:             field("user"),
+			Cgroup:           field("cgroup"),
+			Unit:             field("unit"),
+			CPUPercent:       number("cpu_percent"),
+			RSS:              uint64(number("rss_bytes")),
+			PSS:              uint64(number("pss_bytes")),
+			ReadBytesPerSec:  number("read_bytes_per_sec"),
+			WriteBytesPerSec: number("write_bytes_per_sec"),
+			FDs:              int(number("fds")),
+			Threads:          int(number("threads")),
+		})
+	}
+	return samples, nil
+}
@@ -0,0 +1,119 @@
+package findprocess
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+const gib = 1 << 30
+
+var chargebackStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// chargebackSamples are two runs of a web service owned by alice, the second started as
+// the first exits, and a database owned by bob whose PSS could not be read
+func chargebackSamples() []Sample {
+	at := func(seconds int) time.Time { return chargebackStart.Add(time.Duration(seconds) * time.Second) }
+	web := func(seconds, pID int, cpu float64) Sample {
+		return Sample{Time: at(seconds), Identity: "web-aaaaaaaa", PID: pID, Name: "web", User: "alice", Unit: "web.service",
+			CPUPercent: cpu, RSS: 2 * gib, PSS: gib, ReadBytesPerSec: 1000, WriteBytesPerSec: 10}
+	}
+	db := func(seconds int) Sample {
+		return Sample{Time: at(seconds), Identity: "db-bbbbbbbb", PID: 20, Name: "db", User: "bob",
+			CPUPercent: 100, RSS: gib, WriteBytesPerSec: 1}
+	}
+	return []Sample{
+		web(0, 10, 50), db(30), web(60, 10, 50), web(120, 10, 50),
+		web(120, 11, 10), web(180, 11, 10), db(3630),
+	}
+}
+
+func TestChargebackRoundTrip(t *testing.T) {
+	samples := chargebackSamples()
+
+	var csvBuf, jsonBuf bytes.Buffer
+	if err := NewCSVSampleWriter(&csvBuf).WriteSamples(samples); err != nil {
+		t.Fatal(err)
+	}
+	// samples are written once per interval, so split them across calls
+	jw := NewJSONSampleWriter(&jsonBuf)
+	if err := jw.WriteSamples(samples[:3]); err != nil {
+		t.Fatal(err)
+	}
+	if err := jw.WriteSamples(samples[3:]); err != nil {
+		t.Fatal(err)
+	}
+
+	for format, buf := range map[string]*bytes.Buffer{"csv": &csvBuf, "json": &jsonBuf} {
+		t.Run(format, func(t *testing.T) {
+			read, err := ReadSamples(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(read) != len(samples) {
+				t.Fatalf("read %d samples, want %d", len(read), len(samples))
+			}
+
+			// the first sample of each of the three series is not charged; web's first
+			// run is charged two minutes and its second one minute, db one hour
+			checkCharges(t, Chargeback(read, ChargebackConfig{By: ChargeByUser}), []Charge{
+				{Key: "alice", CPUSeconds: 30 + 30 + 6, MemoryGBHours: 3.0 / 60, ReadBytes: 180000, WriteBytes: 1800},
+				{Key: "bob", CPUSeconds: 3600, MemoryGBHours: 1, WriteBytes: 3600},
+			})
+			checkCharges(t, Chargeback(read, ChargebackConfig{By: ChargeByUnit}), []Charge{
+				{Key: "(none)", CPUSeconds: 3600, MemoryGBHours: 1, WriteBytes: 3600},
+				{Key: "web.service", CPUSeconds: 66, MemoryGBHours: 3.0 / 60, ReadBytes: 180000, WriteBytes: 1800},
+			})
+		})
+	}
+}
+
+func TestChargebackRange(t *testing.T) {
+	// only web's sample at one minute is at or after From and before To
+	charges := Chargeback(chargebackSamples(), ChargebackConfig{
+		By:   ChargeByUser,
+		From: chargebackStart.Add(time.Minute),
+		To:   chargebackStart.Add(2 * time.Minute),
+	})
+	checkCharges(t, charges, []Charge{
+		{Key: "alice", CPUSeconds: 30, MemoryGBHours: 1.0 / 60, ReadBytes: 60000, WriteBytes: 600},
+	})
+}
+
+func TestReadSamplesCSVColumns(t *testing.T) {
+	// an older file without pss_bytes, with its columns in another order
+	data := "pid,time,user,cpu_percent,rss_bytes\n" +
+		"7,2024-01-01T00:00:00Z,carol,25.50,1024\n"
+	samples, err := ReadSamples(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 1 {
+		t.Fatalf("read %d samples, want 1", len(samples))
+	}
+	s := samples[0]
+	if s.PID != 7 || s.User != "carol" || s.CPUPercent != 25.5 || s.RSS != 1024 || s.PSS != 0 || !s.Time.Equal(chargebackStart) {
+		t.Errorf("got %+v", s)
+	}
+
+	if _, err := ReadSamples(strings.NewReader("time,pid\nyesterday,1\n")); err == nil {
+		t.Error("expected an error for an unparseable time")
+	}
+}
+
+func checkCharges(t *testing.T, got, want []Charge) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d charges %+v, want %d", len(got), got, len(want))
+	}
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+	for i := range want {
+		g, w := got[i], want[i]
+		if g.Key != w.Key || !near(g.CPUSeconds, w.CPUSeconds) || !near(g.MemoryGBHours, w.MemoryGBHours) ||
+			g.ReadBytes != w.ReadBytes || g.WriteBytes != w.WriteBytes {
+			t.Errorf("charge %d = %+v, want %+v", i, g, w)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+//go:build linux
+
+package main
+
+import (
+	"flag"
+	"os"
+	"time"
+
+	findprocess "github.com/evdhiggins/go-findprocess"
+)
+
+// runChargeback totals the usage in files written by record, grouped by user, cgroup or unit
+func runChargeback(args []string) error {
+	flags := flag.NewFlagSet("chargeback", flag.ContinueOnError)
+	by := flags.String("by", "user", "group by user, cgroup or unit")
+	from := flags.String("from", "", "only count samples at or after this RFC 3339 time")
+	to := flags.String("to", "", "only count samples before this RFC 3339 time")
+	format := flags.String("format", "csv", "output format, csv or json")
+	if err := flags.Parse(args); err != nil || flags.NArg() == 0 || (*format != "csv" && *format != "json") {
+		return errUsage
+	}
+
+	cfg := findprocess.ChargebackConfig{}
+	var err error
+	if cfg.By, err = findprocess.ParseChargeBy(*by); err != nil {
+		return errUsage
+	}
+	if *from != "" {
+		if cfg.From, err = time.Parse(time.RFC3339, *from); err != nil {
+			return err
+		}
+	}
+	if *to != "" {
+		if cfg.To, err = time.Parse(time.RFC3339, *to); err != nil {
+			return err
+		}
+	}
+
+	var samples []findprocess.Sample
+	for _, name := range flags.Args() {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		s, err := findprocess.ReadSamples(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		samples = append(samples, s...)
+	}
+
+	charges := findprocess.Chargeback(samples, cfg)
+	if *format == "json" {
+		return findprocess.WriteChargesJSON(os.Stdout, charges)
+	}
+	return findprocess.WriteChargesCSV(os.Stdout, charges)
+}
@@ -0,0 +1,44 @@
+//go:build linux
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	findprocess "github.com/evdhiggins/go-findprocess"
+)
+
+// runCollect writes a diagnostics bundle for a process to a file, or to stdout with --out -
+func runCollect(args []string) error {
+	flags := flag.NewFlagSet("collect", flag.ContinueOnError)
+	pID := flags.Int("pid", 0, "process to collect")
+	out := flags.String("out", "", "output file (default findprocess-PID.tar.gz, - for stdout)")
+	if err := flags.Parse(args); err != nil || *pID <= 0 || flags.NArg() != 0 {
+		return errUsage
+	}
+
+	if *out == "" {
+		*out = fmt.Sprintf("findprocess-%d.tar.gz", *pID)
+	}
+
+	if *out == "-" {
+		return findprocess.Collect(*pID, os.Stdout)
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err := findprocess.Collect(*pID, f); err != nil {
+		f.Close()
+		return err
+	}
+	// a failed close can lose the end of the bundle
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "wrote %s\n", *out)
+	return nil
+}
@@ -0,0 +1,43 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	findprocess "github.com/evdhiggins/go-findprocess"
+)
+
+// runCompare prints every property that differs between two processes
+func runCompare(args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+	a, err := strconv.Atoi(args[0])
+	if err != nil {
+		return errUsage
+	}
+	b, err := strconv.Atoi(args[1])
+	if err != nil {
+		return errUsage
+	}
+
+	diffs, err := findprocess.CompareProcesses(a, b)
+	if err != nil {
+		return err
+	}
+	if len(diffs) == 0 {
+		fmt.Println("no differences")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "FIELD\tPID %d\tPID %d\n", a, b)
+	for _, d := range diffs {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", d.Field, d.A, d.B)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,38 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	findprocess "github.com/evdhiggins/go-findprocess"
+)
+
+// runDoctor reports which features will work on the current host
+func runDoctor(args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	report, err := findprocess.Doctor()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, c := range report.Checks {
+		status := "ok"
+		if !c.OK {
+			status = "WARN"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", status, c.Name, c.Detail)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("\n%d processes readable, %d unreadable\n", report.ReadableProcesses, report.UnreadableProcesses)
+	return nil
+}
@@ -0,0 +1,94 @@
+//go:build linux
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	findprocess "github.com/evdhiggins/go-findprocess"
+)
+
+// runKill previews the processes matching a name or pattern, asks for confirmation
+// and terminates them, escalating to SIGKILL after the grace period
+func runKill(args []string) error {
+	flags := flag.NewFlagSet("kill", flag.ContinueOnError)
+	match := flags.String("match", "", "regular expression matched against process names and cmdlines")
+	yes := flags.Bool("yes", false, "terminate without asking for confirmation")
+	grace := flags.Duration("grace", 10*time.Second, "time to wait after SIGTERM before sending SIGKILL")
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+
+	var re *regexp.Regexp
+	switch {
+	case *match != "" && flags.NArg() == 0:
+		var err error
+		if re, err = regexp.Compile(*match); err != nil {
+			return err
+		}
+	case *match == "" && flags.NArg() == 1:
+		// a bare name matches like ByName: the whole process name
+		re = regexp.MustCompile("^" + regexp.QuoteMeta(flags.Arg(0)) + "$")
+	default:
+		return errUsage
+	}
+
+	targets, err := findprocess.KillTargets(re)
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		fmt.Println("no matching processes")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PID\tUSER\tAGE\tCMDLINE")
+	for _, t := range targets {
+		cmdline := strings.Join(t.Cmdline, " ")
+		if cmdline == "" {
+			cmdline = "[" + t.Name + "]"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", t.ID, t.User, t.Age.Round(time.Second), cmdline)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if !*yes {
+		fmt.Printf("Terminate %d processes? [y/N] ", len(targets))
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+			fmt.Println("aborted")
+			return nil
+		}
+	}
+
+	// targets carry their start time, so a PID reused while the prompt waited is not signalled
+	if !printTerminateResults(findprocess.TerminateAll(targets, *grace)) {
+		return fmt.Errorf("some processes were not terminated")
+	}
+	return nil
+}
+
+// printTerminateResults prints the outcome for each process and reports whether all of
+// them were terminated
+func printTerminateResults(results []findprocess.TerminateResult) bool {
+	ok := true
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Printf("%d\t%s: %v\n", r.ID, r.Outcome, r.Err)
+		} else {
+			fmt.Printf("%d\t%s\n", r.ID, r.Outcome)
+		}
+		ok = ok && r.Err == nil && r.Outcome != findprocess.PermissionDenied
+	}
+	return ok
+}
@@ -0,0 +1,65 @@
+//go:build linux
+
+// Command findprocess inspects running processes from the command line
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// errUsage is returned by a command when its arguments are invalid
+var errUsage = errors.New("invalid arguments")
+
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands = map[string]command{
+	"chargeback": {usage: "chargeback [--by user|cgroup|unit] [--from TIME] [--to TIME] [--format csv|json] FILE...", run: runChargeback},
+	"collect":    {usage: "collect --pid PID [--out FILE]", run: runCollect},
+	"compare":    {usage: "compare PID PID", run: runCompare},
+	"doctor":     {usage: "doctor", run: runDoctor},
+	"kill":       {usage: "kill [--yes] [--grace 10s] NAME | --match REGEXP", run: runKill},
+	"pipelines":  {usage: "pipelines [--kill [--yes] [--grace 10s]] [NAME | --match REGEXP]", run: runPipelines},
+	"record":     {usage: "record --match REGEXP [--interval 1s] --out FILE.csv|FILE.jsonl", run: runRecord},
+	"verify":     {usage: "verify --manifest FILE --key FILE [--sig FILE] [--allow-unreadable]", run: runVerify},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+
+	if err := cmd.run(os.Args[2:]); err != nil {
+		if err == errUsage {
+			fmt.Fprintf(os.Stderr, "usage: findprocess %s\n", cmd.usage)
+			os.Exit(2)
+		}
+		fmt.Fprintf(os.Stderr, "findprocess %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(os.Stderr, "usage:")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  findprocess %s\n", commands[name].usage)
+	}
+}
@@ -0,0 +1,86 @@
+//go:build linux
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	findprocess "github.com/evdhiggins/go-findprocess"
+)
+
+// runPipelines lists the processes connected by pipes, optionally only the pipelines
+// with a member matching a name or pattern, and can terminate those pipelines whole
+func runPipelines(args []string) error {
+	flags := flag.NewFlagSet("pipelines", flag.ContinueOnError)
+	match := flags.String("match", "", "regular expression matched against member names and cmdlines")
+	kill := flags.Bool("kill", false, "terminate every member of the matching pipelines")
+	yes := flags.Bool("yes", false, "terminate without asking for confirmation")
+	grace := flags.Duration("grace", 10*time.Second, "time to wait after SIGTERM before sending SIGKILL")
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+
+	var re *regexp.Regexp
+	switch {
+	case *match != "" && flags.NArg() == 0:
+		var err error
+		if re, err = regexp.Compile(*match); err != nil {
+			return err
+		}
+	case *match == "" && flags.NArg() == 1:
+		re = regexp.MustCompile("^" + regexp.QuoteMeta(flags.Arg(0)) + "$")
+	case *match == "" && flags.NArg() == 0 && !*kill:
+	default:
+		return errUsage
+	}
+
+	var pipelines []findprocess.Pipeline
+	var err error
+	if re != nil {
+		pipelines, err = findprocess.PipelinesOf(re)
+	} else {
+		pipelines, err = findprocess.Pipelines()
+	}
+	if err != nil {
+		return err
+	}
+	if len(pipelines) == 0 {
+		fmt.Println("no matching pipelines")
+		return nil
+	}
+
+	for _, p := range pipelines {
+		pIDs := make([]string, len(p.Members))
+		for i, m := range p.Members {
+			pIDs[i] = fmt.Sprint(m.ID)
+		}
+		fmt.Printf("%s\t%s\n", strings.Join(pIDs, ","), p.String())
+	}
+	if !*kill {
+		return nil
+	}
+
+	if !*yes {
+		fmt.Printf("Terminate %d pipelines? [y/N] ", len(pipelines))
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+			fmt.Println("aborted")
+			return nil
+		}
+	}
+
+	failed := false
+	for _, p := range pipelines {
+		failed = !printTerminateResults(findprocess.TerminatePipeline(p, *grace)) || failed
+	}
+	if failed {
+		return fmt.Errorf("some processes were not terminated")
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+//go:build linux
+
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"time"
+
+	findprocess "github.com/evdhiggins/go-findprocess"
+)
+
+// runRecord samples matching processes until interrupted. The output format is
+// chosen by the file extension: .csv for CSV and anything else for JSON lines.
+func runRecord(args []string) error {
+	flags := flag.NewFlagSet("record", flag.ContinueOnError)
+	match := flags.String("match", "", "regular expression matched against process names and cmdlines")
+	interval := flags.Duration("interval", time.Second, "time between samples")
+	out := flags.String("out", "", "output file ending in .csv or .jsonl")
+	if err := flags.Parse(args); err != nil || *match == "" || *out == "" || *interval <= 0 || flags.NArg() != 0 {
+		return errUsage
+	}
+
+	re, err := regexp.Compile(*match)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var w findprocess.SampleWriter = findprocess.NewJSONSampleWriter(f)
+	if strings.HasSuffix(*out, ".csv") {
+		w = findprocess.NewCSVSampleWriter(f)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	return findprocess.NewRecorder(w, findprocess.RecorderConfig{Match: re, Interval: *interval}).Run(ctx)
+}
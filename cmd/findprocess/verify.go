@@ -0,0 +1,84 @@
+//go:build linux
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	findprocess "github.com/evdhiggins/go-findprocess"
+)
+
+// runVerify lists the processes running executables or libraries missing from a signed
+// manifest, failing if there are any or if some processes could not be inspected
+func runVerify(args []string) error {
+	flags := flag.NewFlagSet("verify", flag.ContinueOnError)
+	manifest := flags.String("manifest", "", "file of allowed SHA-256 hashes in sha256sum format")
+	sig := flags.String("sig", "", "ed25519 signature of the manifest (default MANIFEST.sig)")
+	keyFile := flags.String("key", "", "ed25519 public key to check the signature with")
+	allowUnreadable := flags.Bool("allow-unreadable", false, "succeed even if some processes could not be inspected")
+	if err := flags.Parse(args); err != nil || *manifest == "" || *keyFile == "" || flags.NArg() != 0 {
+		return errUsage
+	}
+	if *sig == "" {
+		*sig = *manifest + ".sig"
+	}
+
+	keyData, err := os.ReadFile(*keyFile)
+	if err != nil {
+		return err
+	}
+	key, err := findprocess.ParsePublicKey(keyData)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(*manifest)
+	if err != nil {
+		return err
+	}
+	signature, err := os.ReadFile(*sig)
+	if err != nil {
+		return err
+	}
+	m, err := findprocess.ParseManifest(data, signature, key)
+	if err != nil {
+		return err
+	}
+
+	report, err := findprocess.Verify(m)
+	if err != nil {
+		return err
+	}
+
+	if len(report.Failures) > 0 {
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "PID\tNAME\tFILE\tSHA256")
+		for _, f := range report.Failures {
+			for _, file := range f.Files {
+				hash := file.SHA256
+				switch {
+				case file.Replaced:
+					hash = "(replaced on disk)"
+				case hash == "":
+					hash = "(unreadable)"
+				}
+				fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", f.ID, f.Name, file.Path, hash)
+			}
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		fmt.Println()
+	}
+
+	fmt.Printf("%d processes verified, %d with unlisted files, %d unreadable\n", report.VerifiedProcesses, len(report.Failures), report.UnreadableProcesses)
+	if len(report.Failures) > 0 {
+		return fmt.Errorf("%d processes are running unlisted files", len(report.Failures))
+	}
+	if report.UnreadableProcesses > 0 && !*allowUnreadable {
+		return fmt.Errorf("%d processes could not be inspected; run as root or pass --allow-unreadable", report.UnreadableProcesses)
+	}
+	return nil
+}
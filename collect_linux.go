@@ -0,0 +1,149 @@
+package findprocess
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// collectFiles are the /proc/<pid> files copied verbatim into a diagnostics bundle
+var collectFiles = []string{"status", "stat", "limits", "maps", "smaps_rollup", "cgroup", "mountinfo"}
+
+// collectTaskFiles are the /proc/<pid>/task/<tid> files copied for every thread
+var collectTaskFiles = []string{"comm", "stat", "wchan", "stack"}
+
+// Collect writes a gzipped tar archive of diagnostics for the process with a given pID to w.
+// The archive contains the process's status, stat, limits, maps, smaps_rollup, cgroup and
+// mountinfo files, its open file descriptors, namespaces and redacted cmdline and
+// environment, and the wchan and kernel stack of each thread. Files that cannot be read are listed in errors.txt
+// rather than failing the collection.
+func Collect(pID int, w io.Writer) error {
+	if _, err := readStat(pID); err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(w)
+	c := collector{tw: tar.NewWriter(gz), dir: strconv.Itoa(pID), now: time.Now()}
+
+	for _, name := range collectFiles {
+		c.copyFile(name, procPath(pID, name))
+	}
+	if cmdline, err := readCmdline(pID); err == nil {
+		var buf bytes.Buffer
+		// NUL-terminated arguments, as in /proc
+		for _, arg := range redactCmdline(cmdline) {
+			buf.WriteString(arg)
+			buf.WriteByte(0)
+		}
+		c.add("cmdline", buf.Bytes())
+	} else {
+		c.fail("cmdline", err)
+	}
+	c.add("fd.txt", linkListing(procPath(pID, "fd")))
+	c.add("ns.txt", linkListing(procPath(pID, "ns")))
+	if environ, err := readEnviron(pID); err == nil {
+		c.add("environ.txt", environListing(redactEnviron(environ)))
+	} else {
+		c.fail("environ.txt", err)
+	}
+
+	tIDs, err := os.ReadDir(procPath(pID, "task"))
+	if err != nil {
+		c.fail("task", err)
+	}
+	for _, t := range tIDs {
+		for _, name := range collectTaskFiles {
+			c.copyFile(path.Join("task", t.Name(), name), procPath(pID, "task", t.Name(), name))
+		}
+	}
+
+	if c.errors.Len() > 0 {
+		c.add("errors.txt", c.errors.Bytes())
+	}
+
+	if c.err != nil {
+		return c.err
+	}
+	if err := c.tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// collector writes files into a diagnostics bundle, recording the first write error
+type collector struct {
+	tw     *tar.Writer
+	dir    string
+	now    time.Time
+	errors bytes.Buffer
+	err    error
+}
+
+func (c *collector) copyFile(name, src string) {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		c.fail(name, err)
+		return
+	}
+	c.add(name, data)
+}
+
+func (c *collector) add(name string, data []byte) {
+	if c.err != nil {
+		return
+	}
+
+	hdr := tar.Header{
+		Name:    path.Join(c.dir, name),
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: c.now,
+	}
+	if c.err = c.tw.WriteHeader(&hdr); c.err != nil {
+		return
+	}
+	_, c.err = c.tw.Write(data)
+}
+
+func (c *collector) fail(name string, err error) {
+	fmt.Fprintf(&c.errors, "%s: %v\n", name, err)
+}
+
+// linkListing lists the symlinks in a /proc directory such as fd or ns as "name -> target" lines
+func linkListing(dir string) []byte {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return []byte(err.Error() + "\n")
+	}
+
+	var buf bytes.Buffer
+	for _, e := range entries {
+		target, err := os.Readlink(path.Join(dir, e.Name()))
+		if err != nil {
+			target = err.Error()
+		}
+		fmt.Fprintf(&buf, "%s -> %s\n", e.Name(), target)
+	}
+	return buf.Bytes()
+}
+
+func environListing(environ map[string]string) []byte {
+	keys := make([]string, 0, len(environ))
+	for k := range environ {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	for _, k := range keys {
+		fmt.Fprintf(&buf, "%s=%s\n", k, environ[k])
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,135 @@
+package findprocess
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Difference is a property that differs between two processes. Field names a group
+// and, for keyed properties, the key within it, such as "environ.PATH" or "limits.Max open files".
+type Difference struct {
+	Field string
+	A     string
+	B     string
+}
+
+// CompareProcesses reads the details of two processes and returns every property that
+// differs between them. Redacted cmdline arguments and environment values are compared
+// by their real value, but reported as "[REDACTED]".
+func CompareProcesses(a, b int) ([]Difference, error) {
+	da, err := Details(a)
+	if err != nil {
+		return nil, err
+	}
+	db, err := Details(b)
+	if err != nil {
+		return nil, err
+	}
+	return compareDetails(da, db), nil
+}
+
+func compareDetails(a, b *ProcessDetails) []Difference {
+	var diffs []Difference
+	add := func(field, va, vb string) {
+		if va != vb {
+			diffs = append(diffs, Difference{Field: field, A: va, B: vb})
+		}
+	}
+
+	add("name", a.Name, b.Name)
+	if quoteArgs(a.cmdline) != quoteArgs(b.cmdline) {
+		diffs = append(diffs, Difference{Field: "cmdline", A: quoteArgs(a.Cmdline), B: quoteArgs(b.Cmdline)})
+	}
+	add("user", a.User+" ("+strconv.Itoa(a.UID)+")", b.User+" ("+strconv.Itoa(b.UID)+")")
+	add("gid", strconv.Itoa(a.GID), strconv.Itoa(b.GID))
+	add("ns.uid", strconv.Itoa(a.NamespaceUID), strconv.Itoa(b.NamespaceUID))
+	add("ns.gid", strconv.Itoa(a.NamespaceGID), strconv.Itoa(b.NamespaceGID))
+	add("cwd", a.Cwd, b.Cwd)
+	add("exe", a.Exe, b.Exe)
+	add("exe.sha256", a.ExeSHA256, b.ExeSHA256)
+
+	if a.environ == nil || b.environ == nil {
+		// comparing against an unreadable environment would list every variable
+		add("environ", readable(a.environ != nil), readable(b.environ != nil))
+	} else {
+		diffs = append(diffs, compareEnviron(a, b)...)
+	}
+
+	la, lb := limitMap(a.Limits), limitMap(b.Limits)
+	for _, k := range unionKeys(la, lb) {
+		add("limits."+k, la[k], lb[k])
+	}
+	for _, k := range unionKeys(a.Cgroups, b.Cgroups) {
+		add("cgroup."+k, a.Cgroups[k], b.Cgroups[k])
+	}
+	for _, k := range unionKeys(a.Namespaces, b.Namespaces) {
+		add("ns."+k, a.Namespaces[k], b.Namespaces[k])
+	}
+	for _, set := range capabilitySets {
+		add("caps."+set, a.Capabilities[set], b.Capabilities[set])
+	}
+
+	return diffs
+}
+
+func compareEnviron(a, b *ProcessDetails) []Difference {
+	var diffs []Difference
+	for _, k := range unionKeys(a.environ, b.environ) {
+		va, oka := a.environ[k]
+		vb, okb := b.environ[k]
+		if oka == okb && va == vb {
+			continue
+		}
+		diffs = append(diffs, Difference{Field: "environ." + k, A: presentValue(a.Environ[k], oka), B: presentValue(b.Environ[k], okb)})
+	}
+	return diffs
+}
+
+func readable(ok bool) string {
+	if ok {
+		return "(readable)"
+	}
+	return "(unreadable)"
+}
+
+func limitMap(limits []Limit) map[string]string {
+	m := make(map[string]string, len(limits))
+	for _, l := range limits {
+		m[l.Name] = l.Soft + "/" + l.Hard
+	}
+	return m
+}
+
+func unionKeys(a, b map[string]string) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func presentValue(v string, ok bool) string {
+	if !ok {
+		return "(unset)"
+	}
+	return v
+}
+
+// quoteArgs joins an argument vector, quoting arguments that contain whitespace
+func quoteArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\n\"'") {
+			arg = strconv.Quote(arg)
+		}
+		quoted[i] = arg
+	}
+	return strings.Join(quoted, " ")
+}
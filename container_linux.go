@@ -0,0 +1,183 @@
+package findprocess
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultDockerSocket is the Docker Engine API socket used when none is given
+const DefaultDockerSocket = "/var/run/docker.sock"
+
+// containerCacheTTL is how long a DockerClient reuses a container's details
+const containerCacheTTL = time.Minute
+
+// containerIDPattern matches a container ID in a cgroup path component, such as
+// "docker-<id>.scope", "cri-containerd-<id>.scope", "crio-<id>.scope" or a bare "<id>"
+var containerIDPattern = regexp.MustCompile(`^(?:[a-z-]+-)?([0-9a-f]{64})(?:\.scope)?$`)
+
+// ContainerInfo contains details of the container a process runs in
+type ContainerInfo struct {
+	ID     string
+	Name   string
+	Image  string
+	Labels map[string]string
+	// PodName and PodNamespace are set for containers managed by Kubernetes
+	PodName      string
+	PodNamespace string
+}
+
+// String describes the container, such as "nginx in pod web-7f9 (ns prod)"
+func (c ContainerInfo) String() string {
+	if c.PodName == "" {
+		return c.Name
+	}
+	return fmt.Sprintf("%s in pod %s (ns %s)", c.Name, c.PodName, c.PodNamespace)
+}
+
+// ContainerID returns the ID of the container the process with a given pID runs in,
+// read from its cgroup path. It returns an empty string for processes outside a container.
+func ContainerID(pID int) (string, error) {
+	cgroups, err := readCgroups(pID)
+	if err != nil {
+		return "", err
+	}
+
+	for _, cgroup := range cgroups {
+		for dir := cgroup; dir != "/" && dir != "." && dir != ""; dir = path.Dir(dir) {
+			if m := containerIDPattern.FindStringSubmatch(path.Base(dir)); m != nil {
+				return m[1], nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// DockerClient reads container details from the Docker Engine API over a unix socket,
+// caching them for a minute
+type DockerClient struct {
+	client *http.Client
+	now    func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]cachedContainer
+}
+
+type cachedContainer struct {
+	info    *ContainerInfo
+	fetched time.Time
+}
+
+// NewDockerClient creates a DockerClient that connects to the Docker Engine API on
+// socketPath, or DefaultDockerSocket if it is empty
+func NewDockerClient(socketPath string) *DockerClient {
+	if socketPath == "" {
+		socketPath = DefaultDockerSocket
+	}
+
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socketPath)
+		},
+	}
+	return &DockerClient{
+		client: &http.Client{Transport: transport},
+		now:    time.Now,
+		cache:  make(map[string]cachedContainer),
+	}
+}
+
+// ProcessContainer returns the container the process with a given pID runs in, or nil
+// if it is not in a container the runtime knows of
+func (d *DockerClient) ProcessContainer(ctx context.Context, pID int) (*ContainerInfo, error) {
+	id, err := ContainerID(pID)
+	if err != nil || id == "" {
+		return nil, err
+	}
+	return d.Container(ctx, id)
+}
+
+// Container returns the details of the container with a given ID, or nil if the runtime
+// does not know of it
+func (d *DockerClient) Container(ctx context.Context, id string) (*ContainerInfo, error) {
+	d.mu.Lock()
+	cached, ok := d.cache[id]
+	d.mu.Unlock()
+	if ok && d.now().Sub(cached.fetched) < containerCacheTTL {
+		return cached.info, nil
+	}
+
+	info, err := d.inspect(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := d.now()
+	d.mu.Lock()
+	// expired entries are dropped so that the containers of a long-running watch that
+	// have since exited do not accumulate
+	for cachedID, c := range d.cache {
+		if now.Sub(c.fetched) >= containerCacheTTL {
+			delete(d.cache, cachedID)
+		}
+	}
+	d.cache[id] = cachedContainer{info: info, fetched: now}
+	d.mu.Unlock()
+	return info, nil
+}
+
+// inspect calls the Docker Engine API's container inspect endpoint
+func (d *DockerClient) inspect(ctx context.Context, id string) (*ContainerInfo, error) {
+	// the host is ignored by the unix socket dialer
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://docker/containers/"+url.PathEscape(id)+"/json", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("findprocess: docker inspect %s: %s", id, resp.Status)
+	}
+
+	var body struct {
+		ID     string `json:"Id"`
+		Name   string
+		Config struct {
+			Image  string
+			Labels map[string]string
+		}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	info := ContainerInfo{
+		ID:           body.ID,
+		Name:         strings.TrimPrefix(body.Name, "/"),
+		Image:        body.Config.Image,
+		Labels:       body.Config.Labels,
+		PodName:      body.Config.Labels["io.kubernetes.pod.name"],
+		PodNamespace: body.Config.Labels["io.kubernetes.pod.namespace"],
+	}
+	if name := body.Config.Labels["io.kubernetes.container.name"]; name != "" {
+		info.Name = name
+	}
+	return &info, nil
+}
@@ -0,0 +1,117 @@
+package findprocess
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeDockerSocket serves the container inspect endpoint for containers on a unix socket
+// in a temporary directory, returning the socket's path and a count of the requests made
+func fakeDockerSocket(t *testing.T, containers map[string]interface{}) (string, *int32) {
+	t.Helper()
+	socketPath := filepath.Join(t.TempDir(), "docker.sock")
+	l, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var requests int32
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/containers/"), "/json")
+		c, ok := containers[id]
+		if !ok {
+			http.Error(w, `{"message":"No such container"}`, http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(c)
+	})}
+	go srv.Serve(l)
+	t.Cleanup(func() { srv.Close() })
+	return socketPath, &requests
+}
+
+func TestDockerClientKubernetesLabels(t *testing.T) {
+	socketPath, _ := fakeDockerSocket(t, map[string]interface{}{
+		"abc": map[string]interface{}{
+			"Id":   "abc",
+			"Name": "/k8s_nginx_web-7f9_prod_0",
+			"Config": map[string]interface{}{
+				"Image": "nginx:1.25",
+				"Labels": map[string]string{
+					"io.kubernetes.pod.name":       "web-7f9",
+					"io.kubernetes.pod.namespace":  "prod",
+					"io.kubernetes.container.name": "nginx",
+				},
+			},
+		},
+	})
+
+	info, err := NewDockerClient(socketPath).Container(context.Background(), "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info == nil {
+		t.Fatal("container not found")
+	}
+	if info.Name != "nginx" || info.PodName != "web-7f9" || info.PodNamespace != "prod" || info.Image != "nginx:1.25" {
+		t.Errorf("got %+v", *info)
+	}
+	if got, want := info.String(), "nginx in pod web-7f9 (ns prod)"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestDockerClientNotFound(t *testing.T) {
+	socketPath, _ := fakeDockerSocket(t, nil)
+
+	info, err := NewDockerClient(socketPath).Container(context.Background(), "gone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info != nil {
+		t.Errorf("got %+v for an unknown container, want nil", *info)
+	}
+}
+
+func TestDockerClientCache(t *testing.T) {
+	socketPath, requests := fakeDockerSocket(t, map[string]interface{}{
+		"abc": map[string]interface{}{"Id": "abc", "Name": "/db"},
+		"def": map[string]interface{}{"Id": "def", "Name": "/cache"},
+	})
+	d := NewDockerClient(socketPath)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	d.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if info, err := d.Container(ctx, "abc"); err != nil || info == nil || info.Name != "db" {
+			t.Fatalf("got %v, %v", info, err)
+		}
+	}
+	if n := atomic.LoadInt32(requests); n != 1 {
+		t.Errorf("made %d requests for a cached container, want 1", n)
+	}
+
+	// once the entry expires it is fetched again, and expired entries are evicted
+	now = now.Add(containerCacheTTL)
+	if _, err := d.Container(ctx, "def"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := d.cache["abc"]; ok {
+		t.Error("expired entry was not evicted")
+	}
+	if _, err := d.Container(ctx, "abc"); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(requests); n != 3 {
+		t.Errorf("made %d requests, want 3", n)
+	}
+}
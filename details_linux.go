@@ -0,0 +1,201 @@
+package findprocess
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"strings"
+)
+
+// redacted replaces environment values that look like credentials
+const redacted = "[REDACTED]"
+
+// secretEnvWords are substrings of environment variable names whose values are redacted
+var secretEnvWords = []string{"SECRET", "TOKEN", "PASSWORD", "PASSWD", "KEY", "CREDENTIAL", "AUTH", "COOKIE", "PRIVATE"}
+
+// capabilitySets are the capability lines of /proc/<pid>/status
+var capabilitySets = []string{"CapInh", "CapPrm", "CapEff", "CapBnd", "CapAmb"}
+
+// ProcessDetails contains the properties of a process that are useful when comparing
+// it with another. Fields the caller is not permitted to read are left empty.
+type ProcessDetails struct {
+	Name string
+	ID   int
+	// Cmdline has the values of credential-like arguments, such as --password=, replaced
+	// with "[REDACTED]"
+	Cmdline []string
+	// Environ has the values of credential-like variables replaced with "[REDACTED]"
+	Environ map[string]string
+	Limits  []Limit
+	// Cgroups maps each cgroup hierarchy's controllers to the process's path in it;
+	// the unified hierarchy has an empty key
+	Cgroups map[string]string
+	// Namespaces maps a namespace type such as "net" to its identifier, such as "net:[4026531840]"
+	Namespaces map[string]string
+	// Capabilities maps a capability set such as "CapEff" to its hexadecimal mask
+	Capabilities map[string]string
+	UID          int
+	GID          int
+	User         string
+	// NamespaceUID and NamespaceGID are UID and GID translated into the process's user namespace
+	NamespaceUID int
+	NamespaceGID int
+	Cwd          string
+	Exe          string
+	ExeSHA256    string
+
+	cmdline []string
+	environ map[string]string
+}
+
+// Details reads the properties of the process with a given pID
+func Details(pID int) (*ProcessDetails, error) {
+	stat, err := readStat(pID)
+	if err != nil {
+		return nil, err
+	}
+
+	d := ProcessDetails{Name: stat.Comm, ID: pID}
+	d.cmdline, _ = readCmdline(pID)
+	d.Cmdline = redactCmdline(d.cmdline)
+	d.environ, _ = readEnviron(pID)
+	d.Environ = redactEnviron(d.environ)
+	d.Limits, _ = readLimits(pID)
+	d.Cgroups, _ = readCgroups(pID)
+	d.Namespaces = readNamespaces(pID)
+	d.Cwd, _ = os.Readlink(procPath(pID, "cwd"))
+	d.Exe, _ = os.Readlink(procPath(pID, "exe"))
+	d.ExeSHA256, _ = fileSHA256(procPath(pID, "exe"))
+
+	if status, err := readStatus(pID); err == nil {
+		d.UID, _ = statusID(status, "Uid")
+		d.GID, _ = statusID(status, "Gid")
+		d.User = userName(d.UID)
+		d.NamespaceUID = mapToNamespace(pID, "uid_map", d.UID)
+		d.NamespaceGID = mapToNamespace(pID, "gid_map", d.GID)
+		d.Capabilities = make(map[string]string, len(capabilitySets))
+		for _, set := range capabilitySets {
+			d.Capabilities[set] = status[set]
+		}
+	}
+
+	return &d, nil
+}
+
+// readEnviron returns the unredacted environment of a process
+func readEnviron(pID int) (map[string]string, error) {
+	data, err := os.ReadFile(procPath(pID, "environ"))
+	if err != nil {
+		return nil, err
+	}
+
+	environ := make(map[string]string)
+	for _, kv := range bytes.Split(data, []byte{0}) {
+		if len(kv) == 0 {
+			continue
+		}
+		parts := strings.SplitN(string(kv), "=", 2)
+		if len(parts) == 2 {
+			environ[parts[0]] = parts[1]
+		} else {
+			environ[parts[0]] = ""
+		}
+	}
+	return environ, nil
+}
+
+func redactEnviron(environ map[string]string) map[string]string {
+	if environ == nil {
+		return nil
+	}
+
+	results := make(map[string]string, len(environ))
+	for k, v := range environ {
+		if isSecretEnv(k) {
+			v = redacted
+		}
+		results[k] = v
+	}
+	return results
+}
+
+// redactCmdline replaces the values of credential-like arguments, as in
+// "--password=hunter2", "--token hunter2" or "PASSWORD=hunter2"
+func redactCmdline(cmdline []string) []string {
+	results := make([]string, len(cmdline))
+	secretNext := false
+	for i, arg := range cmdline {
+		results[i] = arg
+		if secretNext {
+			results[i] = redacted
+			secretNext = false
+			continue
+		}
+		if eq := strings.IndexByte(arg, '='); eq >= 0 {
+			if isSecretEnv(arg[:eq]) {
+				results[i] = arg[:eq+1] + redacted
+			}
+		} else if strings.HasPrefix(arg, "-") && arg != "--" {
+			secretNext = isSecretEnv(arg)
+		}
+	}
+	return results
+}
+
+func isSecretEnv(name string) bool {
+	upper := strings.ToUpper(name)
+	for _, word := range secretEnvWords {
+		if strings.Contains(upper, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// readCgroups returns a process's path in every cgroup hierarchy, keyed by the hierarchy's controllers
+func readCgroups(pID int) (map[string]string, error) {
+	data, err := os.ReadFile(procPath(pID, "cgroup"))
+	if err != nil {
+		return nil, err
+	}
+
+	cgroups := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) == 3 {
+			cgroups[parts[1]] = parts[2]
+		}
+	}
+	return cgroups, nil
+}
+
+func readNamespaces(pID int) map[string]string {
+	entries, err := os.ReadDir(procPath(pID, "ns"))
+	if err != nil {
+		return nil
+	}
+
+	namespaces := make(map[string]string, len(entries))
+	for _, e := range entries {
+		if target, err := os.Readlink(procPath(pID, "ns", e.Name())); err == nil {
+			namespaces[e.Name()] = target
+		}
+	}
+	return namespaces
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
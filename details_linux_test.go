@@ -0,0 +1,68 @@
+package findprocess
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRedactCmdline(t *testing.T) {
+	tests := []struct {
+		name    string
+		cmdline []string
+		want    []string
+	}{
+		{
+			"inline value",
+			[]string{"mysql", "--password=hunter2", "--host=db"},
+			[]string{"mysql", "--password=[REDACTED]", "--host=db"},
+		},
+		{
+			"separate value",
+			[]string{"vault", "login", "--token", "s.abc", "--verbose"},
+			[]string{"vault", "login", "--token", "[REDACTED]", "--verbose"},
+		},
+		{
+			"environment assignment",
+			[]string{"env", "PASSWORD=x", "HOME=/root", "app"},
+			[]string{"env", "PASSWORD=[REDACTED]", "HOME=/root", "app"},
+		},
+		{
+			// the arguments after -- may be a wrapped command's own flags
+			"after end of options",
+			[]string{"sudo", "--", "app", "--secret", "x"},
+			[]string{"sudo", "--", "app", "--secret", "[REDACTED]"},
+		},
+		{
+			"end of options after a secret flag",
+			[]string{"app", "--", "--api-key=abc"},
+			[]string{"app", "--", "--api-key=[REDACTED]"},
+		},
+		{
+			"trailing flag without a value",
+			[]string{"app", "--token"},
+			[]string{"app", "--token"},
+		},
+		{
+			"case insensitive",
+			[]string{"app", "-Auth-Token", "abc"},
+			[]string{"app", "-Auth-Token", "[REDACTED]"},
+		},
+		{
+			"no secrets",
+			[]string{"sleep", "30"},
+			[]string{"sleep", "30"},
+		},
+		{"empty", nil, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := append([]string(nil), tt.cmdline...)
+			if got := redactCmdline(tt.cmdline); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("redactCmdline(%q) = %q, want %q", tt.cmdline, got, tt.want)
+			}
+			if !reflect.DeepEqual(tt.cmdline, input) {
+				t.Errorf("redactCmdline modified its input to %q", tt.cmdline)
+			}
+		})
+	}
+}
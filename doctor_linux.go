@@ -0,0 +1,324 @@
+package findprocess
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// sysPidfdOpen is the pidfd_open syscall number, which is shared by every architecture but alpha
+const sysPidfdOpen = 434
+
+// proc connector constants from linux/connector.h and linux/cn_proc.h
+const (
+	netlinkConnector  = 11
+	cnIdxProc         = 1
+	cnValProc         = 1
+	procCnMcastListen = 1
+	procCnMcastIgnore = 2
+	procEventNone     = 0
+)
+
+// procConnectorTimeout is how long checkProcConnector waits for the kernel to acknowledge
+const procConnectorTimeout = time.Second
+
+// nativeEndian is the byte order of netlink messages, which is the host's
+var nativeEndian binary.ByteOrder = binary.LittleEndian
+
+func init() {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 0 {
+		nativeEndian = binary.BigEndian
+	}
+}
+
+// capabilities the package's features depend on, by bit number
+var doctorCapabilities = []struct {
+	bit     uint
+	name    string
+	affects string
+}{
+	{2, "CAP_DAC_READ_SEARCH", "reading other users' /proc files such as fd and maps"},
+	{5, "CAP_KILL", "signalling other users' processes"},
+	{12, "CAP_NET_ADMIN", "listening on the proc connector for process events before Linux 6.6"},
+	{19, "CAP_SYS_PTRACE", "reading other users' environ, exe, fd and kernel stacks"},
+	{21, "CAP_SYS_ADMIN", "reading kernel stacks and some cgroup files"},
+}
+
+// DoctorCheck is the result of checking one host feature
+type DoctorCheck struct {
+	Name string
+	// OK is false if the feature limits what the package can report
+	OK     bool
+	Detail string
+}
+
+// DoctorReport describes which features will work on the current host and why
+type DoctorReport struct {
+	Checks []DoctorCheck
+	// ReadableProcesses and UnreadableProcesses count the user-space processes whose exe
+	// and environ can and cannot be read; unreadable processes are found by name but not inspected
+	ReadableProcesses   int
+	UnreadableProcesses int
+}
+
+// Doctor checks the current host for the features the package depends on. Results that
+// are missing or empty elsewhere in the package can usually be explained by a failed check.
+func Doctor() (*DoctorReport, error) {
+	var r DoctorReport
+	r.Checks = append(r.Checks,
+		checkProcMount(),
+		checkCgroups(),
+		checkPidfd(),
+		checkProcConnector(),
+		checkPtraceScope(),
+	)
+
+	// capabilities held in a user namespace other than the initial one, as in a rootless
+	// container, only apply to processes owned by that namespace
+	capEff := effectiveCapabilities()
+	initialNS := inInitialUserNamespace()
+	for _, c := range doctorCapabilities {
+		check := DoctorCheck{Name: c.name, OK: capEff&(1<<c.bit) != 0}
+		switch {
+		case check.OK && !initialNS:
+			check.OK = false
+			check.Detail = "held only in a child user namespace; does not enable " + c.affects + " outside it"
+		case check.OK:
+			check.Detail = "held; enables " + c.affects
+		default:
+			check.Detail = "not held; needed for " + c.affects
+		}
+		r.Checks = append(r.Checks, check)
+	}
+
+	pIDs, err := listPIDs()
+	if err != nil {
+		return nil, err
+	}
+	for _, pID := range pIDs {
+		// kernel threads have no executable or environment to read
+		if stat, err := readStat(pID); err != nil || pID == 2 || stat.PPID == 2 {
+			continue
+		}
+		_, errExe := os.Readlink(procPath(pID, "exe"))
+		f, errEnv := os.Open(procPath(pID, "environ"))
+		if errEnv == nil {
+			f.Close()
+		}
+		switch {
+		case errExe == nil && errEnv == nil:
+			r.ReadableProcesses++
+		case os.IsNotExist(errExe) && os.IsNotExist(errEnv):
+			// the process exited while being checked
+		default:
+			r.UnreadableProcesses++
+		}
+	}
+
+	return &r, nil
+}
+
+func checkProcMount() DoctorCheck {
+	check := DoctorCheck{Name: "proc mount"}
+
+	data, err := os.ReadFile(procRoot + "/self/mountinfo")
+	if err != nil {
+		check.Detail = err.Error()
+		return check
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		// mount ID, parent ID, major:minor, root, mount point, options, ... - type source super options
+		fields := strings.Fields(line)
+		if len(fields) < 5 || fields[4] != procRoot {
+			continue
+		}
+		i := strings.Index(line, " - ")
+		if i < 0 {
+			continue
+		}
+		super := strings.Fields(line[i+3:])
+		if len(super) < 3 {
+			continue
+		}
+		for _, opt := range strings.Split(super[2], ",") {
+			if strings.HasPrefix(opt, "hidepid=") && opt != "hidepid=0" && opt != "hidepid=off" {
+				check.Detail = fmt.Sprintf("mounted with %s; other users' processes are hidden or unreadable", opt)
+				return check
+			}
+		}
+		check.OK = true
+		check.Detail = "mounted without hidepid; all processes are visible"
+		return check
+	}
+
+	check.Detail = "not mounted at " + procRoot
+	return check
+}
+
+func checkCgroups() DoctorCheck {
+	check := DoctorCheck{Name: "cgroups", OK: true}
+	switch {
+	case fileExists(filepath.Join(cgroupRoot, "cgroup.controllers")):
+		check.Detail = "v2 (unified)"
+	case fileExists(filepath.Join(cgroupRoot, "unified", "cgroup.controllers")):
+		check.Detail = "hybrid; limits are read from v1 controllers"
+	case fileExists(filepath.Join(cgroupRoot, "memory")):
+		check.Detail = "v1"
+	default:
+		check.OK = false
+		check.Detail = "not mounted at " + cgroupRoot + "; cgroup limits and container IDs are unavailable"
+	}
+	return check
+}
+
+func checkPidfd() DoctorCheck {
+	check := DoctorCheck{Name: "pidfd"}
+	fd, _, errno := syscall.Syscall(sysPidfdOpen, uintptr(os.Getpid()), 0, 0)
+	if errno != 0 {
+		check.Detail = "pidfd_open failed: " + errno.Error() + "; needs Linux 5.3 or later"
+		return check
+	}
+	syscall.Close(int(fd))
+	check.OK = true
+	check.Detail = "supported"
+	return check
+}
+
+// checkProcConnector subscribes to the proc connector and waits for the kernel to
+// acknowledge. The kernel only answers callers in the initial user and PID namespaces,
+// and only accepts those with CAP_NET_ADMIN there, so holding the capability is not enough.
+func checkProcConnector() DoctorCheck {
+	check := DoctorCheck{Name: "proc connector"}
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, netlinkConnector)
+	if err != nil {
+		check.Detail = "netlink connector socket failed: " + err.Error() + "; the kernel may lack CONFIG_CONNECTOR"
+		return check
+	}
+	defer syscall.Close(fd)
+
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: cnIdxProc}); err != nil {
+		check.Detail = "binding to process events failed: " + err.Error()
+		return check
+	}
+	tv := syscall.NsecToTimeval(int64(procConnectorTimeout))
+	if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv); err != nil {
+		check.Detail = err.Error()
+		return check
+	}
+
+	// the kernel numbers its messages itself, so the request is matched by its ack field
+	ack := uint32(os.Getpid())
+	if err := sendProcConnectorOp(fd, ack, procCnMcastListen); err != nil {
+		check.Detail = "subscribing to process events failed: " + err.Error()
+		return check
+	}
+	defer sendProcConnectorOp(fd, ack, procCnMcastIgnore)
+
+	errno, ok := readProcConnectorAck(fd, ack)
+	switch {
+	case !ok:
+		check.Detail = "no acknowledgement; process events need the initial user, PID and network namespaces"
+	case errno != 0:
+		check.Detail = "subscribing was refused: " + errno.Error() + "; needs CAP_NET_ADMIN in the initial user namespace"
+	default:
+		check.OK = true
+		check.Detail = "process events are available"
+	}
+	return check
+}
+
+// sendProcConnectorOp sends a multicast listen or ignore request to the proc connector
+func sendProcConnectorOp(fd int, ack uint32, op uint32) error {
+	// nlmsghdr (16 bytes), cn_msg (20 bytes) and the operation
+	msg := make([]byte, 40)
+	nativeEndian.PutUint32(msg[0:], uint32(len(msg)))
+	nativeEndian.PutUint16(msg[4:], syscall.NLMSG_DONE)
+	nativeEndian.PutUint32(msg[12:], uint32(os.Getpid()))
+	nativeEndian.PutUint32(msg[16:], cnIdxProc)
+	nativeEndian.PutUint32(msg[20:], cnValProc)
+	nativeEndian.PutUint32(msg[28:], ack)
+	nativeEndian.PutUint16(msg[32:], 4)
+	nativeEndian.PutUint32(msg[36:], op)
+	return syscall.Sendto(fd, msg, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK})
+}
+
+// readProcConnectorAck waits for the kernel's acknowledgement of the request sent with
+// ack, which it answers with ack+1, skipping other process events, and returns the
+// error it carries
+func readProcConnectorAck(fd int, ack uint32) (syscall.Errno, bool) {
+	buf := make([]byte, 4096)
+	deadline := time.Now().Add(procConnectorTimeout)
+	for time.Now().Before(deadline) {
+		n, _, err := syscall.Recvfrom(fd, buf, 0)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return 0, false
+		}
+		// nlmsghdr, then cn_msg with its ack at 28, then proc_event with what at 36
+		// and an acknowledgement's error at 52
+		if n < 56 || nativeEndian.Uint32(buf[28:]) != ack+1 || nativeEndian.Uint32(buf[36:]) != procEventNone {
+			continue
+		}
+		return syscall.Errno(nativeEndian.Uint32(buf[52:])), true
+	}
+	return 0, false
+}
+
+// inInitialUserNamespace reports whether the current process is in the initial user
+// namespace, whose uid_map maps every ID to itself
+func inInitialUserNamespace() bool {
+	data, err := os.ReadFile(procPath(os.Getpid(), "uid_map"))
+	if err != nil {
+		return true
+	}
+	fields := strings.Fields(string(data))
+	return len(fields) == 3 && fields[0] == "0" && fields[1] == "0" && fields[2] == "4294967295"
+}
+
+func checkPtraceScope() DoctorCheck {
+	check := DoctorCheck{Name: "ptrace_scope"}
+	data, err := os.ReadFile(procRoot + "/sys/kernel/yama/ptrace_scope")
+	if err != nil {
+		check.OK = true
+		check.Detail = "Yama is not enabled; ptrace access follows the usual UID and capability rules"
+		return check
+	}
+
+	// Yama only restricts attach-mode access, which /proc/<pid>/stack needs but other /proc reads do not
+	scope := strings.TrimSpace(string(data))
+	switch scope {
+	case "0", "1":
+		check.OK = true
+		check.Detail = scope + "; /proc reads are unaffected"
+	case "2":
+		check.Detail = "2; only CAP_SYS_PTRACE can read kernel stacks"
+	default:
+		check.Detail = scope + "; attaching is disabled, so kernel stacks are unreadable"
+	}
+	return check
+}
+
+// effectiveCapabilities returns the effective capability mask of the current process
+func effectiveCapabilities() uint64 {
+	status, err := readStatus(os.Getpid())
+	if err != nil {
+		return 0
+	}
+	mask, _ := strconv.ParseUint(status["CapEff"], 16, 64)
+	return mask
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,139 @@
+package findprocess
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// fileReleasePollInterval is how often WaitFileReleased checks for holders
+const fileReleasePollInterval = 250 * time.Millisecond
+
+// FileHolder contains a process that has a file open or mapped into memory
+type FileHolder struct {
+	Name string
+	ID   int
+	// FDs are the file descriptors the process has the file open on
+	FDs []int
+	// Mapped is true if the file is mapped into the process's memory, as a shared library or mmap
+	Mapped bool
+}
+
+// FileHolders finds the processes that have the file at path open or mapped. The file is
+// matched by device and inode, so it is found through any of its names.
+func FileHolders(path string) ([]FileHolder, error) {
+	st, path, err := statHeldFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return holdersOf(st, path)
+}
+
+// WaitFileReleased blocks until no process has the file at path open or mapped. The file
+// is identified when the wait starts, so it is still waited on if it is then deleted or
+// replaced by another file at path. If ctx is done first, the processes still holding
+// the file are returned with ctx's error.
+func WaitFileReleased(ctx context.Context, path string) ([]FileHolder, error) {
+	st, path, err := statHeldFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	ticker := time.NewTicker(fileReleasePollInterval)
+	defer ticker.Stop()
+
+	for {
+		holders, err := holdersOf(st, path)
+		if err != nil {
+			return nil, err
+		}
+		if len(holders) == 0 {
+			return nil, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return holders, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// statHeldFile returns the device and inode of the file at path and its absolute path
+func statHeldFile(path string) (*syscall.Stat_t, string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, "", err
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil, "", errMalformed("stat")
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return st, path, nil
+}
+
+// holdersOf finds the processes that have the file with st's device and inode open or
+// mapped. path is only used to recognise mappings on overlay filesystems.
+func holdersOf(st *syscall.Stat_t, path string) ([]FileHolder, error) {
+	procs, err := processes()
+	if err != nil {
+		return nil, err
+	}
+
+	var holders []FileHolder
+	for _, p := range procs {
+		h := FileHolder{Name: p.Filename, ID: p.ProcessID}
+		h.FDs = openFDs(p.ProcessID, st)
+		h.Mapped = isMapped(p.ProcessID, st, path)
+		if len(h.FDs) > 0 || h.Mapped {
+			holders = append(holders, h)
+		}
+	}
+	return holders, nil
+}
+
+func openFDs(pID int, st *syscall.Stat_t) []int {
+	entries, err := os.ReadDir(procPath(pID, "fd"))
+	if err != nil {
+		return nil
+	}
+
+	var fds []int
+	for _, e := range entries {
+		var fdStat syscall.Stat_t
+		if syscall.Stat(procPath(pID, "fd", e.Name()), &fdStat) != nil {
+			continue
+		}
+		if fdStat.Dev == st.Dev && fdStat.Ino == st.Ino {
+			if fd, err := strconv.Atoi(e.Name()); err == nil {
+				fds = append(fds, fd)
+			}
+		}
+	}
+	return fds
+}
+
+// isMapped reports whether a process maps the file. Overlay filesystems report a
+// different device in maps than stat does, so the path is also compared.
+func isMapped(pID int, st *syscall.Stat_t, path string) bool {
+	maps, err := readMaps(pID)
+	if err != nil {
+		return false
+	}
+
+	for _, e := range maps {
+		if e.file.ino != st.Ino {
+			continue
+		}
+		if e.file.dev == uint64(st.Dev) || e.path == path {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package findprocess
+
+import (
+	"os"
+	"strings"
+	"time"
+)
+
+// LinuxProcess is an implementation of Process for Linux.
+type LinuxProcess struct {
+	ProcessID int
+	ParentID  int
+	Filename  string
+	// StartTime is the time the process started after boot, in clock ticks
+	StartTime uint64
+	// CPUTime is the user and system CPU time the process has used
+	CPUTime time.Duration
+	// RSS is the resident set size in bytes
+	RSS uint64
+}
+
+func processes() ([]LinuxProcess, error) {
+	pIDs, err := listPIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]LinuxProcess, 0, len(pIDs))
+	for _, pID := range pIDs {
+		stat, err := readStat(pID)
+		if err != nil {
+			// the process exited between listing /proc and reading it
+			continue
+		}
+		results = append(results, newLinuxProcess(stat))
+	}
+	return results, nil
+}
+
+// findProcessByName matches name against comm, which the kernel truncates to 15 bytes,
+// so a longer name is compared with the basename of the process's exe or cmdline[0]
+func findProcessByName(processes []LinuxProcess, name string) *LinuxProcess {
+	for _, p := range processes {
+		if p.Filename == name {
+			return &p
+		}
+		if len(name) > commLen && len(p.Filename) == commLen && strings.HasPrefix(name, p.Filename) {
+			exe, _ := os.Readlink(procPath(p.ProcessID, "exe"))
+			argv0 := ""
+			if cmdline, err := readCmdline(p.ProcessID); err == nil && len(cmdline) > 0 {
+				argv0 = cmdline[0]
+			}
+			if untruncatedName(p.Filename, exe, argv0) == name {
+				return &p
+			}
+		}
+	}
+	return nil
+}
+
+func findProcessByID(processes []LinuxProcess, pID int) *LinuxProcess {
+	for _, p := range processes {
+		if pID == p.ProcessID {
+			return &p
+		}
+	}
+	return nil
+}
+
+func newLinuxProcess(s *procStat) LinuxProcess {
+	return LinuxProcess{
+		ProcessID: s.PID,
+		ParentID:  s.PPID,
+		Filename:  s.Comm,
+		StartTime: s.StartTime,
+		CPUTime:   ticksToDuration(s.UTime + s.STime),
+		RSS:       uint64(s.RSS) * pageSize,
+	}
+}
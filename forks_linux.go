@@ -0,0 +1,291 @@
+package findprocess
+
+import (
+	"context"
+	"os/user"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// maxProcessesLimit is the name of RLIMIT_NPROC in /proc/<pid>/limits
+const maxProcessesLimit = "Max processes"
+
+// UserProcessCount contains the number of processes and threads owned by a user,
+// measured against the user's RLIMIT_NPROC
+type UserProcessCount struct {
+	UID       int
+	User      string
+	Processes int
+	Threads   int
+	// Limit is the lowest RLIMIT_NPROC soft limit among the user's processes, or 0 if unlimited.
+	// The kernel counts threads, not processes, against this limit, and never applies it
+	// to root, whose Limit is always 0.
+	Limit   uint64
+	Parents []ParentCount
+}
+
+// ParentCount contains the number of children a parent process has
+type ParentCount struct {
+	Name     string
+	ID       int
+	Children int
+}
+
+// ForkEventKind identifies why a ForkEvent was sent
+type ForkEventKind int
+
+const (
+	// ForkRateSpike is sent when the system-wide fork rate exceeds ForkWatchConfig.MaxForkRate
+	ForkRateSpike ForkEventKind = iota
+	// UserNearLimit is sent when a user's thread count reaches ForkWatchConfig.LimitRatio of their RLIMIT_NPROC
+	UserNearLimit
+)
+
+// ForkEvent is sent by WatchForks
+type ForkEvent struct {
+	Kind ForkEventKind
+	Time time.Time
+	// ForkRate is the system-wide number of forks per second over the last interval
+	ForkRate float64
+	// User is set for UserNearLimit events
+	User *UserProcessCount
+	// Parents lists the most prolific parent processes, most children first. For a
+	// ForkRateSpike these are the parents of processes started during the last interval.
+	Parents []ParentCount
+}
+
+// ForkWatchConfig configures WatchForks
+type ForkWatchConfig struct {
+	// Interval is the time between samples; it defaults to a second
+	Interval time.Duration
+	// MaxForkRate is the number of forks per second above which a ForkRateSpike is sent; 0 disables it
+	MaxForkRate float64
+	// LimitRatio is the fraction of RLIMIT_NPROC at which a UserNearLimit is sent, such as 0.9; 0 disables it
+	LimitRatio float64
+	// TopParents is the number of parents named in each event; it defaults to 5
+	TopParents int
+}
+
+// ForkCount returns the number of forks since boot, from the processes counter in /proc/stat
+func ForkCount() (uint64, error) {
+	stat, err := readProcStat()
+	if err != nil {
+		return 0, err
+	}
+	if !stat.hasForks {
+		return 0, errMalformed("stat")
+	}
+	return stat.forks, nil
+}
+
+// UserProcessCounts returns the process and thread counts of every user with a running process
+func UserProcessCounts() ([]UserProcessCount, error) {
+	tasks, err := userTasks()
+	if err != nil {
+		return nil, err
+	}
+	return countUsers(tasks, 5), nil
+}
+
+// WatchForks samples the fork rate and per-user process counts every interval and sends
+// an event when the rate spikes or a user approaches their limit. A user is reported
+// again only after dropping back below the limit ratio. The channel is closed when ctx is done.
+func WatchForks(ctx context.Context, cfg ForkWatchConfig) (<-chan ForkEvent, error) {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second
+	}
+	if cfg.TopParents <= 0 {
+		cfg.TopParents = 5
+	}
+
+	lastCount, err := ForkCount()
+	if err != nil {
+		return nil, err
+	}
+	lastTasks, err := userTasks()
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan ForkEvent)
+	go func() {
+		defer close(events)
+
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+
+		lastTime := time.Now()
+		nearLimit := make(map[int]bool)
+		send := func(e ForkEvent) bool {
+			select {
+			case events <- e:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				count, err := ForkCount()
+				if err != nil {
+					continue
+				}
+				tasks, err := userTasks()
+				if err != nil {
+					continue
+				}
+
+				rate := float64(count-lastCount) / now.Sub(lastTime).Seconds()
+				if cfg.MaxForkRate > 0 && rate > cfg.MaxForkRate {
+					e := ForkEvent{Kind: ForkRateSpike, Time: now, ForkRate: rate, Parents: newTaskParents(lastTasks, tasks, cfg.TopParents)}
+					if !send(e) {
+						return
+					}
+				}
+
+				if cfg.LimitRatio > 0 {
+					for _, u := range countUsers(tasks, cfg.TopParents) {
+						near := u.Limit > 0 && float64(u.Threads) >= cfg.LimitRatio*float64(u.Limit)
+						if near && !nearLimit[u.UID] {
+							u := u
+							if !send(ForkEvent{Kind: UserNearLimit, Time: now, ForkRate: rate, User: &u, Parents: u.Parents}) {
+								return
+							}
+						}
+						nearLimit[u.UID] = near
+					}
+				}
+
+				lastCount, lastTasks, lastTime = count, tasks, now
+			}
+		}
+	}()
+	return events, nil
+}
+
+// userTask is a process along with the details needed to charge it to a user
+type userTask struct {
+	LinuxProcess
+	UID     int
+	Threads int
+	Limit   uint64
+}
+
+func userTasks() (map[int]userTask, error) {
+	pIDs, err := listPIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	tasks := make(map[int]userTask, len(pIDs))
+	for _, pID := range pIDs {
+		stat, err := readStat(pID)
+		if err != nil {
+			continue
+		}
+		status, err := readStatus(pID)
+		if err != nil {
+			continue
+		}
+		uID, ok := statusID(status, "Uid")
+		if !ok {
+			continue
+		}
+		tasks[pID] = userTask{
+			LinuxProcess: newLinuxProcess(stat),
+			UID:          uID,
+			Threads:      stat.NumThreads,
+			Limit:        nprocLimit(pID),
+		}
+	}
+	return tasks, nil
+}
+
+// nprocLimit returns the RLIMIT_NPROC soft limit of a process, or 0 if it is unlimited or unreadable
+func nprocLimit(pID int) uint64 {
+	limits, err := readLimits(pID)
+	if err != nil {
+		return 0
+	}
+	for _, l := range limits {
+		if l.Name == maxProcessesLimit {
+			n, _ := strconv.ParseUint(l.Soft, 10, 64)
+			return n
+		}
+	}
+	return 0
+}
+
+// countUsers totals the processes and threads of each user. Kernel threads are left out,
+// as they belong to no user's limit.
+func countUsers(tasks map[int]userTask, topParents int) []UserProcessCount {
+	users := make(map[int]*UserProcessCount)
+	children := make(map[int][]userTask)
+	for pID, t := range tasks {
+		if pID == 2 || t.ParentID == 2 {
+			continue
+		}
+		u, ok := users[t.UID]
+		if !ok {
+			u = &UserProcessCount{UID: t.UID, User: userName(t.UID)}
+			users[t.UID] = u
+		}
+		u.Processes++
+		u.Threads += t.Threads
+		// the kernel exempts root from RLIMIT_NPROC
+		if t.UID != 0 && t.Limit > 0 && (u.Limit == 0 || t.Limit < u.Limit) {
+			u.Limit = t.Limit
+		}
+		children[t.UID] = append(children[t.UID], t)
+	}
+
+	results := make([]UserProcessCount, 0, len(users))
+	for uID, u := range users {
+		u.Parents = topParentCounts(children[uID], tasks, topParents)
+		results = append(results, *u)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Threads > results[j].Threads })
+	return results
+}
+
+// newTaskParents returns the parents of processes in current that were not in previous
+func newTaskParents(previous, current map[int]userTask, n int) []ParentCount {
+	var started []userTask
+	for pID, t := range current {
+		if _, ok := previous[pID]; !ok {
+			started = append(started, t)
+		}
+	}
+	return topParentCounts(started, current, n)
+}
+
+func topParentCounts(children []userTask, tasks map[int]userTask, n int) []ParentCount {
+	counts := make(map[int]int)
+	for _, c := range children {
+		counts[c.ParentID]++
+	}
+
+	parents := make([]ParentCount, 0, len(counts))
+	for pID, count := range counts {
+		parents = append(parents, ParentCount{Name: tasks[pID].Filename, ID: pID, Children: count})
+	}
+	sort.Slice(parents, func(i, j int) bool { return parents[i].Children > parents[j].Children })
+	if len(parents) > n {
+		parents = parents[:n]
+	}
+	return parents
+}
+
+// userName returns the login name for a UID, or the UID itself if it has no passwd entry
+func userName(uID int) string {
+	u, err := user.LookupId(strconv.Itoa(uID))
+	if err != nil {
+		return strconv.Itoa(uID)
+	}
+	return u.Username
+}
@@ -0,0 +1,170 @@
+package findprocess
+
+import (
+	"debug/buildinfo"
+	"fmt"
+	"go/version"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// containerAwareGo is the first Go release whose default GOMAXPROCS respects the cgroup CPU limit
+const containerAwareGo = "go1.25"
+
+// GoRuntimeCheck contains the Go runtime settings of a Go process compared with its cgroup limits
+type GoRuntimeCheck struct {
+	Name      string
+	ID        int
+	GoVersion string
+	// GOMAXPROCS and GOMEMLIMIT are the values in the process's environ, or empty if unset
+	GOMAXPROCS string
+	GOMEMLIMIT string
+	// MaxProcs is the GOMAXPROCS the runtime will use
+	MaxProcs int
+	// CPULimit is the cgroup CPU quota in CPUs, or 0 if unlimited
+	CPULimit float64
+	// MemoryLimit is the cgroup memory limit in bytes, or 0 if unlimited
+	MemoryLimit uint64
+	// Problems describes each setting that will get the process throttled or OOM-killed
+	Problems []string
+}
+
+// CheckGoRuntime compares the GOMAXPROCS and GOMEMLIMIT of the process with a given pID
+// with its cgroup's CPU and memory limits. It returns nil if the process is not a Go binary.
+func CheckGoRuntime(pID int) (*GoRuntimeCheck, error) {
+	stat, err := readStat(pID)
+	if err != nil {
+		return nil, err
+	}
+	info, err := buildinfo.ReadFile(procPath(pID, "exe"))
+	if err != nil {
+		// not a Go binary, or the executable is not readable
+		return nil, nil
+	}
+	environ, err := readEnviron(pID)
+	if err != nil {
+		return nil, err
+	}
+
+	c := GoRuntimeCheck{
+		Name:        stat.Comm,
+		ID:          pID,
+		GoVersion:   info.GoVersion,
+		GOMAXPROCS:  environ["GOMAXPROCS"],
+		GOMEMLIMIT:  environ["GOMEMLIMIT"],
+		CPULimit:    cgroupCPULimit(pID),
+		MemoryLimit: cgroupMemoryLimit(pID),
+	}
+
+	cpus := allowedCPUs(pID)
+	c.MaxProcs = cpus
+	if n, err := strconv.Atoi(c.GOMAXPROCS); err == nil && n > 0 {
+		c.MaxProcs = n
+	} else if c.CPULimit > 0 && containerAware(c.GoVersion, defaultGODEBUG(info)+","+environ["GODEBUG"]) {
+		// the runtime rounds the quota up, with a minimum of 2
+		c.MaxProcs = int(math.Min(float64(cpus), math.Max(2, math.Ceil(c.CPULimit))))
+	}
+
+	// the runtime's own floor of 2 is not reported, as it is the Go team's chosen trade-off
+	if c.CPULimit > 0 && float64(c.MaxProcs) > math.Max(2, math.Ceil(c.CPULimit)) {
+		c.Problems = append(c.Problems, fmt.Sprintf("GOMAXPROCS is %d but the cgroup allows %.2f CPUs; the process will be throttled", c.MaxProcs, c.CPULimit))
+	}
+
+	if c.MemoryLimit > 0 {
+		memLimit, ok := parseGoMemLimit(c.GOMEMLIMIT)
+		switch {
+		case !ok:
+			c.Problems = append(c.Problems, fmt.Sprintf("GOMEMLIMIT is %q, which the runtime will not accept", c.GOMEMLIMIT))
+		case memLimit == 0:
+			c.Problems = append(c.Problems, fmt.Sprintf("GOMEMLIMIT is unset but the cgroup memory limit is %d bytes; the GC does not account for the limit and the heap can grow until the process is OOM-killed", c.MemoryLimit))
+		case memLimit >= c.MemoryLimit:
+			c.Problems = append(c.Problems, fmt.Sprintf("GOMEMLIMIT is %s but the cgroup memory limit is %d bytes; the process will be OOM-killed first", c.GOMEMLIMIT, c.MemoryLimit))
+		}
+	}
+
+	return &c, nil
+}
+
+// GoRuntimeProblems checks every running Go process and returns those with problems
+func GoRuntimeProblems() ([]GoRuntimeCheck, error) {
+	pIDs, err := listPIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	var results []GoRuntimeCheck
+	for _, pID := range pIDs {
+		c, err := CheckGoRuntime(pID)
+		if err != nil || c == nil || len(c.Problems) == 0 {
+			continue
+		}
+		results = append(results, *c)
+	}
+	return results, nil
+}
+
+// containerAware reports whether a Go release defaults GOMAXPROCS to the cgroup CPU limit.
+// godebug holds the binary's default settings followed by GODEBUG; the last one wins.
+func containerAware(goVersion, godebug string) bool {
+	if version.Compare(goVersion, containerAwareGo) < 0 {
+		return false
+	}
+	aware := true
+	for _, setting := range strings.Split(godebug, ",") {
+		switch strings.TrimSpace(setting) {
+		case "containermaxprocs=0":
+			aware = false
+		case "containermaxprocs=1":
+			aware = true
+		}
+	}
+	return aware
+}
+
+// defaultGODEBUG returns the GODEBUG defaults compiled into a binary. A main module that
+// declares a go version older than the toolchain gets that version's defaults, such as
+// containermaxprocs=0 for go 1.24 built by go1.25.
+func defaultGODEBUG(info *buildinfo.BuildInfo) string {
+	for _, s := range info.Settings {
+		if s.Key == "DefaultGODEBUG" {
+			return s.Value
+		}
+	}
+	return ""
+}
+
+// parseGoMemLimit parses a GOMEMLIMIT value, returning 0 if it is unset or "off"
+func parseGoMemLimit(s string) (uint64, bool) {
+	if s == "" || s == "off" {
+		return 0, true
+	}
+
+	// longest suffixes first so "KiB" is not read as "B"
+	units := []struct {
+		suffix string
+		scale  uint64
+	}{{"TiB", 1 << 40}, {"GiB", 1 << 30}, {"MiB", 1 << 20}, {"KiB", 1 << 10}, {"B", 1}}
+	scale := uint64(1)
+	for _, u := range units {
+		if strings.HasSuffix(s, u.suffix) {
+			s, scale = strings.TrimSuffix(s, u.suffix), u.scale
+			break
+		}
+	}
+
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return n * scale, true
+}
+
+// allowedCPUs returns the number of CPUs a process may run on, from Cpus_allowed_list
+func allowedCPUs(pID int) int {
+	status, err := readStatus(pID)
+	if err != nil {
+		return 0
+	}
+	return len(parseCPUList(status["Cpus_allowed_list"]))
+}
@@ -0,0 +1,285 @@
+package findprocess
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// posixShmDir is where POSIX shared memory objects and named semaphores live
+const posixShmDir = "/dev/shm"
+
+// SharedMemorySegment is a System V shared memory segment
+type SharedMemorySegment struct {
+	Key  int64
+	ID   int
+	Size uint64
+	// RSS and Swap are the segment's resident and swapped memory in bytes
+	RSS   uint64
+	Swap  uint64
+	Owner string
+	// CreatorID is the PID that created the segment and LastID the last to attach or detach it
+	CreatorID int
+	LastID    int
+	// Attachments is the kernel's count of attachments; a segment with none is not freed
+	// until it is removed, so it may have been leaked by a process that crashed
+	Attachments int
+	// Attachers are the processes found mapping the segment
+	Attachers []ProcessStatus
+}
+
+// SemaphoreSet is a System V semaphore set. The kernel does not record its creator's PID.
+type SemaphoreSet struct {
+	Key        int64
+	ID         int
+	Semaphores int
+	Owner      string
+}
+
+// MessageQueue is a System V message queue
+type MessageQueue struct {
+	Key      int64
+	ID       int
+	Bytes    uint64
+	Messages int
+	Owner    string
+	// SenderID and ReceiverID are the PIDs that last sent and received a message
+	SenderID   int
+	ReceiverID int
+}
+
+// POSIXSharedMemory is a file in /dev/shm, such as a shm_open object or a named semaphore.
+// Files mapped from a container's own /dev/shm or deleted while still mapped are included.
+type POSIXSharedMemory struct {
+	Path string
+	// Size is the file's size, or for a file outside our /dev/shm the largest mapping of it
+	Size uint64
+	// Owner is empty for a file outside our /dev/shm
+	Owner   string
+	Deleted bool
+	// Attachers are the processes that map the file or have it open
+	Attachers []ProcessStatus
+}
+
+// IPCReport contains the System V and POSIX IPC objects of the host and the processes using them
+type IPCReport struct {
+	SharedMemory  []SharedMemorySegment
+	Semaphores    []SemaphoreSet
+	MessageQueues []MessageQueue
+	POSIX         []POSIXSharedMemory
+}
+
+// IPCObjects reads the System V IPC objects from /proc/sysvipc and the POSIX shared memory
+// in /dev/shm, and attributes them to the processes that map or open them. Only the
+// processes the caller may inspect are found as attachers, and System V segments are
+// only attributed to processes in the caller's IPC namespace.
+func IPCObjects() (*IPCReport, error) {
+	r := IPCReport{}
+
+	shm, err := readSysvIPC("shm")
+	if err != nil {
+		return nil, err
+	}
+	segments := make(map[int]int, len(shm))
+	for _, row := range shm {
+		s := SharedMemorySegment{
+			Key:         row.int64("key"),
+			ID:          int(row.int64("shmid")),
+			Size:        row.uint64("size"),
+			RSS:         row.uint64("rss"),
+			Swap:        row.uint64("swap"),
+			Owner:       userName(int(row.int64("uid"))),
+			CreatorID:   int(row.int64("cpid")),
+			LastID:      int(row.int64("lpid")),
+			Attachments: int(row.int64("nattch")),
+		}
+		segments[s.ID] = len(r.SharedMemory)
+		r.SharedMemory = append(r.SharedMemory, s)
+	}
+
+	sem, err := readSysvIPC("sem")
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range sem {
+		r.Semaphores = append(r.Semaphores, SemaphoreSet{
+			Key:        row.int64("key"),
+			ID:         int(row.int64("semid")),
+			Semaphores: int(row.int64("nsems")),
+			Owner:      userName(int(row.int64("uid"))),
+		})
+	}
+
+	msg, err := readSysvIPC("msg")
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range msg {
+		r.MessageQueues = append(r.MessageQueues, MessageQueue{
+			Key:        row.int64("key"),
+			ID:         int(row.int64("msqid")),
+			Bytes:      row.uint64("cbytes"),
+			Messages:   int(row.int64("qnum")),
+			Owner:      userName(int(row.int64("uid"))),
+			SenderID:   int(row.int64("lspid")),
+			ReceiverID: int(row.int64("lrpid")),
+		})
+	}
+
+	posix, byFile := readPOSIXShm()
+
+	// /proc/sysvipc lists only our IPC namespace, whose shmids mean nothing in another
+	ipcNS, _ := os.Readlink(procPath(os.Getpid(), "ns", "ipc"))
+	// fromMaps marks the POSIX objects known only from mappings, whose size is estimated
+	fromMaps := make(map[int]bool)
+
+	procs, err := processes()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range procs {
+		status := ProcessStatus{Name: p.Filename, ID: p.ProcessID, IsRunning: true}
+		ns, err := os.Readlink(procPath(p.ProcessID, "ns", "ipc"))
+		sameIPC := err == nil && ns == ipcNS
+		// a process usually maps an object more than once but is listed once
+		attachedSegments, attached := make(map[int]bool), make(map[int]bool)
+		for _, m := range shmMappings(p.ProcessID) {
+			if strings.HasPrefix(m.path, "/SYSV") {
+				if i, ok := segments[int(m.ino)]; ok && sameIPC && !attachedSegments[i] {
+					attachedSegments[i] = true
+					r.SharedMemory[i].Attachers = append(r.SharedMemory[i].Attachers, status)
+				}
+				continue
+			}
+
+			// a file not in our /dev/shm is deleted or on a container's own tmpfs
+			i, ok := byFile[m.file]
+			if !ok {
+				i = len(posix)
+				byFile[m.file] = i
+				fromMaps[i] = true
+				posix = append(posix, POSIXSharedMemory{Path: strings.TrimSuffix(m.path, " (deleted)"), Deleted: strings.HasSuffix(m.path, " (deleted)")})
+			}
+			if fromMaps[i] && m.size > posix[i].Size {
+				posix[i].Size = m.size
+			}
+			if !attached[i] {
+				attached[i] = true
+				posix[i].Attachers = append(posix[i].Attachers, status)
+			}
+		}
+		for _, f := range shmFDs(p.ProcessID) {
+			if i, ok := byFile[f]; ok && !attached[i] {
+				attached[i] = true
+				posix[i].Attachers = append(posix[i].Attachers, status)
+			}
+		}
+	}
+	r.POSIX = posix
+
+	return &r, nil
+}
+
+// sysvIPCRow is a row of a /proc/sysvipc file keyed by its column names
+type sysvIPCRow map[string]string
+
+func (r sysvIPCRow) int64(column string) int64 {
+	n, _ := strconv.ParseInt(r[column], 10, 64)
+	return n
+}
+
+func (r sysvIPCRow) uint64(column string) uint64 {
+	n, _ := strconv.ParseUint(r[column], 10, 64)
+	return n
+}
+
+// readSysvIPC reads one of /proc/sysvipc/shm, sem or msg, whose first line names the columns
+func readSysvIPC(name string) ([]sysvIPCRow, error) {
+	data, err := os.ReadFile(filepath.Join(procRoot, "sysvipc", name))
+	if err != nil {
+		return nil, err
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	columns := strings.Fields(lines[0])
+	var rows []sysvIPCRow
+	for _, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if len(fields) != len(columns) {
+			return nil, errMalformed("sysvipc/" + name)
+		}
+		row := make(sysvIPCRow, len(columns))
+		for i, c := range columns {
+			row[c] = fields[i]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// readPOSIXShm lists the files in /dev/shm, indexing them by device and inode
+func readPOSIXShm() ([]POSIXSharedMemory, map[fileID]int) {
+	var results []POSIXSharedMemory
+	byFile := make(map[fileID]int)
+
+	entries, _ := os.ReadDir(posixShmDir)
+	for _, e := range entries {
+		var st syscall.Stat_t
+		path := filepath.Join(posixShmDir, e.Name())
+		if syscall.Stat(path, &st) != nil || st.Mode&syscall.S_IFMT != syscall.S_IFREG {
+			continue
+		}
+		byFile[fileID{uint64(st.Dev), st.Ino}] = len(results)
+		results = append(results, POSIXSharedMemory{Path: path, Size: uint64(st.Size), Owner: userName(int(st.Uid))})
+	}
+	return results, byFile
+}
+
+// shmMapping is a mapping of System V or POSIX shared memory
+type shmMapping struct {
+	path string
+	file fileID
+	ino  uint64
+	size uint64
+}
+
+// shmMappings returns a process's mappings of System V segments, whose inode is their
+// shmid, and of files in /dev/shm
+func shmMappings(pID int) []shmMapping {
+	maps, err := readMaps(pID)
+	if err != nil {
+		return nil
+	}
+
+	var results []shmMapping
+	for _, e := range maps {
+		if !strings.HasPrefix(e.path, "/SYSV") && !strings.HasPrefix(e.path, posixShmDir+"/") {
+			continue
+		}
+		results = append(results, shmMapping{path: e.path, file: e.file, ino: e.file.ino, size: e.end - e.start})
+	}
+	return results
+}
+
+// shmFDs returns the files in /dev/shm a process has open
+func shmFDs(pID int) []fileID {
+	entries, err := os.ReadDir(procPath(pID, "fd"))
+	if err != nil {
+		return nil
+	}
+
+	var results []fileID
+	for _, e := range entries {
+		target, err := os.Readlink(procPath(pID, "fd", e.Name()))
+		if err != nil || !strings.HasPrefix(target, posixShmDir+"/") {
+			continue
+		}
+		var st syscall.Stat_t
+		if syscall.Stat(procPath(pID, "fd", e.Name()), &st) == nil {
+			results = append(results, fileID{uint64(st.Dev), st.Ino})
+		}
+	}
+	return results
+}
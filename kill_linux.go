@@ -0,0 +1,280 @@
+package findprocess
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"syscall"
+	"time"
+)
+
+// terminatePollInterval is how often Terminate checks whether a process has exited
+const terminatePollInterval = 50 * time.Millisecond
+
+// sysPidfdSendSignal is the pidfd_send_signal syscall number, which like pidfd_open is
+// shared by every architecture but alpha
+const sysPidfdSendSignal = 424
+
+// TerminateOutcome is how a Terminate call ended
+type TerminateOutcome int
+
+const (
+	// Exited means the process exited within the grace period after SIGTERM
+	Exited TerminateOutcome = iota
+	// Escalated means the process ignored SIGTERM and was sent SIGKILL
+	Escalated
+	// PermissionDenied means the caller may not signal the process
+	PermissionDenied
+	// AlreadyGone means the process had exited before it was signalled
+	AlreadyGone
+	// Refused means a safety guard refused to signal the process
+	Refused
+)
+
+func (o TerminateOutcome) String() string {
+	switch o {
+	case Exited:
+		return "exited"
+	case Escalated:
+		return "escalated"
+	case PermissionDenied:
+		return "permission denied"
+	case AlreadyGone:
+		return "already gone"
+	case Refused:
+		return "refused"
+	}
+	return "unknown"
+}
+
+// KillTarget contains the details of a process that are shown before terminating it
+type KillTarget struct {
+	Name    string
+	ID      int
+	User    string
+	Age     time.Duration
+	Cmdline []string
+	// StartTime is the process's start time in clock ticks since boot, which tells it
+	// apart from a later process that reuses its PID
+	StartTime uint64
+}
+
+// TerminateResult is the outcome of terminating one process with TerminateAll
+type TerminateResult struct {
+	ID      int
+	Outcome TerminateOutcome
+	Err     error
+}
+
+// KillTargets finds the processes whose name or cmdline matches re, leaving out those
+// the safety guards would refuse to terminate. re must not be nil, as that would select
+// every process.
+func KillTargets(re *regexp.Regexp) ([]KillTarget, error) {
+	if re == nil {
+		return nil, fmt.Errorf("findprocess: refusing to select every process for termination")
+	}
+	up, err := uptime()
+	if err != nil {
+		return nil, err
+	}
+	procs, err := processes()
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []KillTarget
+	for _, p := range procs {
+		cmdline, _ := readCmdline(p.ProcessID)
+		if !matchesProcess(re, p.Filename, cmdline) || checkKillSafe(p.ProcessID) != nil {
+			continue
+		}
+		targets = append(targets, KillTarget{
+			Name:      p.Filename,
+			ID:        p.ProcessID,
+			User:      userName(effectiveUID(p.ProcessID)),
+			Age:       time.Duration((up - float64(p.StartTime)/clockTicks) * float64(time.Second)),
+			Cmdline:   cmdline,
+			StartTime: p.StartTime,
+		})
+	}
+	return targets, nil
+}
+
+// Terminate asks the process with a given pID to exit with SIGTERM and sends SIGKILL if
+// it is still running after grace. It refuses to signal init, kernel threads, the
+// current process or its ancestors; the reason is returned as the error.
+func Terminate(pID int, grace time.Duration) (TerminateOutcome, error) {
+	if err := checkKillSafe(pID); err != nil {
+		return Refused, err
+	}
+
+	// the start time tells a reused PID apart from the process being terminated
+	stat, err := readStat(pID)
+	if err != nil {
+		return AlreadyGone, nil
+	}
+
+	r := TerminateAll([]KillTarget{{Name: stat.Comm, ID: pID, StartTime: stat.StartTime}}, grace)[0]
+	return r.Outcome, r.Err
+}
+
+// TerminateAll sends SIGTERM to every target, waits up to grace once for all of them to
+// exit and then sends SIGKILL to those still running. A target whose PID now belongs to
+// a process with a different start time is AlreadyGone and is not signalled. Signals are
+// sent through a pidfd where the kernel supports one, so a PID cannot be reused between
+// the check and the signal.
+func TerminateAll(targets []KillTarget, grace time.Duration) []TerminateResult {
+	results := make([]TerminateResult, len(targets))
+	handles := make([]*processHandle, len(targets))
+	for i, t := range targets {
+		results[i].ID = t.ID
+		if err := checkKillSafe(t.ID); err != nil {
+			results[i].Outcome, results[i].Err = Refused, err
+			continue
+		}
+
+		h, err := openProcess(t.ID, t.StartTime)
+		if err == nil {
+			err = h.signal(syscall.SIGTERM)
+		}
+		if err != nil {
+			if h != nil {
+				h.close()
+			}
+			results[i].Outcome, results[i].Err = signalFailure(err)
+			continue
+		}
+		handles[i] = h
+	}
+
+	deadline := time.Now().Add(grace)
+	for i, h := range handles {
+		if h == nil {
+			continue
+		}
+		results[i].Outcome, results[i].Err = escalate(h, time.Until(deadline))
+		h.close()
+	}
+	return results
+}
+
+// escalate waits up to timeout for a process sent SIGTERM to exit, then sends SIGKILL
+func escalate(h *processHandle, timeout time.Duration) (TerminateOutcome, error) {
+	if waitExit(h.pID, h.startTime, timeout) {
+		return Exited, nil
+	}
+	if err := h.signal(syscall.SIGKILL); err != nil {
+		if err == syscall.ESRCH {
+			// it exited between the grace period ending and SIGKILL
+			return Exited, nil
+		}
+		return signalFailure(err)
+	}
+	return Escalated, nil
+}
+
+// processHandle refers to one process, through a pidfd where the kernel supports them
+type processHandle struct {
+	pID       int
+	startTime uint64
+	pidfd     int
+}
+
+// openProcess opens a handle to the process with a given pID, returning ESRCH if it has
+// exited or its PID now belongs to a process with a different start time
+func openProcess(pID int, startTime uint64) (*processHandle, error) {
+	h := processHandle{pID: pID, startTime: startTime, pidfd: -1}
+	fd, _, errno := syscall.Syscall(sysPidfdOpen, uintptr(pID), 0, 0)
+	switch errno {
+	case 0:
+		h.pidfd = int(fd)
+	case syscall.ESRCH:
+		return nil, syscall.ESRCH
+	}
+
+	// once the pidfd is open, a matching start time proves it refers to the expected process
+	stat, err := readStat(pID)
+	if err != nil || stat.StartTime != startTime {
+		h.close()
+		return nil, syscall.ESRCH
+	}
+	return &h, nil
+}
+
+func (h *processHandle) signal(sig syscall.Signal) error {
+	if h.pidfd < 0 {
+		return syscall.Kill(h.pID, sig)
+	}
+	if _, _, errno := syscall.Syscall6(sysPidfdSendSignal, uintptr(h.pidfd), uintptr(sig), 0, 0, 0, 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func (h *processHandle) close() {
+	if h.pidfd >= 0 {
+		syscall.Close(h.pidfd)
+		h.pidfd = -1
+	}
+}
+
+// signalFailure converts an error from kill(2) to an outcome
+func signalFailure(err error) (TerminateOutcome, error) {
+	switch err {
+	case syscall.ESRCH:
+		return AlreadyGone, nil
+	case syscall.EPERM:
+		return PermissionDenied, nil
+	}
+	return Refused, err
+}
+
+// waitExit waits up to timeout for a process to exit. A zombie has exited even though
+// its parent has not reaped it yet.
+func waitExit(pID int, startTime uint64, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		stat, err := readStat(pID)
+		if err != nil || stat.StartTime != startTime || stat.State == 'Z' {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(terminatePollInterval)
+	}
+}
+
+// checkKillSafe returns an error if pID is a process that must never be signalled
+func checkKillSafe(pID int) error {
+	switch {
+	case pID <= 1:
+		return fmt.Errorf("findprocess: refusing to signal PID %d", pID)
+	case pID == os.Getpid():
+		return fmt.Errorf("findprocess: refusing to signal the current process")
+	case callerAncestors()[pID]:
+		// signalling the caller's shell or sudo would end the session it was run from
+		return fmt.Errorf("findprocess: refusing to signal PID %d, an ancestor of the current process", pID)
+	}
+
+	stat, err := readStat(pID)
+	if err == nil && (pID == 2 || stat.PPID == 2) {
+		return fmt.Errorf("findprocess: refusing to signal kernel thread %s (%d)", stat.Comm, pID)
+	}
+	return nil
+}
+
+// callerAncestors returns the PIDs of the current process's parent, its parent's
+// parent and so on up to init
+func callerAncestors() map[int]bool {
+	ancestors := make(map[int]bool)
+	for pID := os.Getppid(); pID > 1 && !ancestors[pID]; {
+		ancestors[pID] = true
+		stat, err := readStat(pID)
+		if err != nil {
+			break
+		}
+		pID = stat.PPID
+	}
+	return ancestors
+}
@@ -0,0 +1,147 @@
+package findprocess
+
+import (
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+// unsetLoginID is the value of loginuid and sessionid for processes outside a login session
+const unsetLoginID = 4294967295
+
+// LoginInfo contains the login session a process belongs to. The login UID is set by
+// PAM at login and is inherited unchanged through sudo and su, so it names the user
+// who actually started a process.
+type LoginInfo struct {
+	Name string
+	ID   int
+	// LoginUID is -1 for processes started outside a login session, such as by init
+	LoginUID  int
+	LoginUser string
+	// SessionID is the audit session ID, or -1 outside a login session
+	SessionID int
+	UID       int
+	User      string
+	// UIDChanges lists where the effective UID changed along the ancestor path, oldest first
+	UIDChanges []UIDChange
+}
+
+// UIDChange is a process whose effective UID differs from its parent's
+type UIDChange struct {
+	Name       string
+	ID         int
+	UID        int
+	User       string
+	ParentName string
+	ParentID   int
+	ParentUID  int
+	ParentUser string
+}
+
+// Login reads the login session of the process with a given pID and the UID changes
+// between it and init
+func Login(pID int) (*LoginInfo, error) {
+	stat, err := readStat(pID)
+	if err != nil {
+		return nil, err
+	}
+
+	info := LoginInfo{Name: stat.Comm, ID: pID}
+	info.LoginUID = readLoginID(pID, "loginuid")
+	info.SessionID = readLoginID(pID, "sessionid")
+	if info.LoginUID >= 0 {
+		info.LoginUser = userName(info.LoginUID)
+	}
+
+	info.UID = effectiveUID(pID)
+	info.User = userName(info.UID)
+
+	child := struct {
+		name string
+		id   int
+		uID  int
+	}{stat.Comm, pID, info.UID}
+	for parentID := stat.PPID; parentID > 0; {
+		parent, err := readStat(parentID)
+		if err != nil {
+			break
+		}
+		parentUID := effectiveUID(parentID)
+		if parentUID >= 0 && child.uID >= 0 && parentUID != child.uID {
+			change := UIDChange{
+				Name:       child.name,
+				ID:         child.id,
+				UID:        child.uID,
+				User:       userName(child.uID),
+				ParentName: parent.Comm,
+				ParentID:   parentID,
+				ParentUID:  parentUID,
+				ParentUser: userName(parentUID),
+			}
+			info.UIDChanges = append([]UIDChange{change}, info.UIDChanges...)
+		}
+		child.name, child.id, child.uID = parent.Comm, parentID, parentUID
+		parentID = parent.PPID
+	}
+
+	return &info, nil
+}
+
+// StartedBy finds the running processes whose login user is userName. userName may
+// also be a numeric UID.
+func StartedBy(userName string) ([]ProcessStatus, error) {
+	uID, err := strconv.Atoi(userName)
+	if err != nil {
+		u, err := user.Lookup(userName)
+		if err != nil {
+			return nil, err
+		}
+		if uID, err = strconv.Atoi(u.Uid); err != nil {
+			return nil, err
+		}
+	}
+
+	procs, err := processes()
+	if err != nil {
+		return nil, err
+	}
+
+	var results []ProcessStatus
+	for _, p := range procs {
+		if readLoginID(p.ProcessID, "loginuid") == uID {
+			results = append(results, ProcessStatus{Name: p.Filename, ID: p.ProcessID, IsRunning: true})
+		}
+	}
+	return results, nil
+}
+
+// readLoginID reads loginuid or sessionid, returning -1 if it is unset or cannot be read
+func readLoginID(pID int, name string) int {
+	data, err := os.ReadFile(procPath(pID, name))
+	if err != nil {
+		return -1
+	}
+	id, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 32)
+	if err != nil || id == unsetLoginID {
+		return -1
+	}
+	return int(id)
+}
+
+// effectiveUID returns the effective UID of a process, or -1 if it cannot be read
+func effectiveUID(pID int) int {
+	status, err := readStatus(pID)
+	if err != nil {
+		return -1
+	}
+	fields := strings.Fields(status["Uid"])
+	if len(fields) < 2 {
+		return -1
+	}
+	uID, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return -1
+	}
+	return uID
+}
@@ -0,0 +1,164 @@
+package findprocess
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// nodeRoot is the sysfs directory describing NUMA nodes
+const nodeRoot = "/sys/devices/system/node"
+
+// NUMAUsage contains how a process's memory is spread across NUMA nodes and how much
+// of it is backed by huge pages
+type NUMAUsage struct {
+	Name string
+	ID   int
+	// Nodes maps a NUMA node to the bytes of the process's memory resident on it
+	Nodes map[int]uint64
+	// HomeNode is the node the process's CPU affinity confines it to, or the node
+	// holding most of its memory if its affinity spans nodes
+	HomeNode int
+	// Imbalance is the fraction of the process's memory that is not on its home node
+	Imbalance float64
+	// AnonHugePages is the transparent huge page memory, in bytes
+	AnonHugePages uint64
+	// HugetlbPages is the hugetlbfs memory, shared and private, in bytes
+	HugetlbPages uint64
+}
+
+// NUMAPlacement reads the per-node memory and huge page usage of the process with a given pID
+func NUMAPlacement(pID int) (*NUMAUsage, error) {
+	stat, err := readStat(pID)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := readNUMAMaps(pID)
+	if err != nil {
+		return nil, err
+	}
+
+	u := NUMAUsage{Name: stat.Comm, ID: pID, Nodes: nodes, HomeNode: -1}
+	if rollup, err := readKeyValues(procPath(pID, "smaps_rollup")); err == nil {
+		u.AnonHugePages = rollup["AnonHugePages"]
+		u.HugetlbPages = rollup["Shared_Hugetlb"] + rollup["Private_Hugetlb"]
+	}
+
+	var total, most uint64
+	for node, bytes := range nodes {
+		total += bytes
+		if bytes > most || (bytes == most && node < u.HomeNode) {
+			u.HomeNode, most = node, bytes
+		}
+	}
+	if node, ok := affinityNode(pID); ok {
+		u.HomeNode = node
+	}
+	if total > 0 {
+		u.Imbalance = 1 - float64(nodes[u.HomeNode])/float64(total)
+	}
+
+	return &u, nil
+}
+
+// NUMAImbalanced returns the processes matching re whose fraction of memory away from
+// their home node exceeds maxImbalance, such as 0.1
+func NUMAImbalanced(re *regexp.Regexp, maxImbalance float64) ([]NUMAUsage, error) {
+	procs, err := processes()
+	if err != nil {
+		return nil, err
+	}
+
+	var results []NUMAUsage
+	for _, p := range procs {
+		cmdline, _ := readCmdline(p.ProcessID)
+		if !matchesProcess(re, p.Filename, cmdline) {
+			continue
+		}
+		u, err := NUMAPlacement(p.ProcessID)
+		if err != nil || u.Imbalance <= maxImbalance {
+			continue
+		}
+		results = append(results, *u)
+	}
+	return results, nil
+}
+
+// readNUMAMaps sums the pages of every mapping in /proc/<pid>/numa_maps by node. Lines
+// look like "7f0c... default file=/usr/lib/libc.so mapped=5 N0=3 N1=2 kernelpagesize_kB=4".
+func readNUMAMaps(pID int) (map[int]uint64, error) {
+	f, err := os.Open(procPath(pID, "numa_maps"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	nodes := make(map[int]uint64)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		pageSize := uint64(4096)
+		pages := make(map[int]uint64)
+		for _, field := range strings.Fields(scanner.Text()) {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			n, err := strconv.ParseUint(kv[1], 10, 64)
+			if err != nil {
+				continue
+			}
+			switch {
+			case kv[0] == "kernelpagesize_kB":
+				pageSize = n * 1024
+			case strings.HasPrefix(kv[0], "N"):
+				if node, err := strconv.Atoi(kv[0][1:]); err == nil {
+					pages[node] += n
+				}
+			}
+		}
+		for node, n := range pages {
+			nodes[node] += n * pageSize
+		}
+	}
+	return nodes, scanner.Err()
+}
+
+// affinityNode returns the NUMA node a process's CPU affinity confines it to, if it is
+// confined to exactly one
+func affinityNode(pID int) (int, bool) {
+	status, err := readStatus(pID)
+	if err != nil {
+		return 0, false
+	}
+	allowed := make(map[int]bool)
+	for _, cpu := range parseCPUList(status["Cpus_allowed_list"]) {
+		allowed[cpu] = true
+	}
+
+	dirs, err := filepath.Glob(filepath.Join(nodeRoot, "node[0-9]*"))
+	if err != nil {
+		return 0, false
+	}
+	home, homes := 0, 0
+	for _, dir := range dirs {
+		node, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(dir), "node"))
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, "cpulist"))
+		if err != nil {
+			continue
+		}
+		for _, cpu := range parseCPUList(string(data)) {
+			if allowed[cpu] {
+				home = node
+				homes++
+				break
+			}
+		}
+	}
+	return home, homes == 1
+}
@@ -0,0 +1,200 @@
+package findprocess
+
+import (
+	"context"
+	"regexp"
+	"time"
+)
+
+// MemoryForecast contains a process's memory trend and how long it has until it, or its
+// cgroup, runs out of memory
+type MemoryForecast struct {
+	Name string
+	ID   int
+	// Identity is the process's name and a hash of its cmdline, as in Sample, which stays
+	// the same across restarts but tells apart different services with the same name
+	Identity string
+	Time     time.Time
+	// Memory is the process's PSS in bytes, or its RSS where PSS cannot be read
+	Memory uint64
+	// Growth is the trend of Memory over the sample window, in bytes per second
+	Growth float64
+	// CgroupHeadroom is the number of bytes left before the tightest cgroup memory limit;
+	// it is only meaningful if CgroupLimited is true
+	CgroupHeadroom uint64
+	CgroupLimited  bool
+	// MemAvailable is the system's MemAvailable from /proc/meminfo, in bytes
+	MemAvailable uint64
+	// OOMKills is the number of OOM kills in the process's cgroup since the watch began
+	OOMKills uint64
+	// TimeToOOM is the time until the headroom runs out at the current growth, or 0 if memory is not growing
+	TimeToOOM time.Duration
+}
+
+// MemoryWatchConfig configures WatchMemory
+type MemoryWatchConfig struct {
+	// Match selects the processes to watch by their name or space-separated cmdline
+	Match *regexp.Regexp
+	// Interval is the time between samples; it defaults to a second
+	Interval time.Duration
+	// Window is the number of samples the growth trend is fitted over; it defaults to 10
+	Window int
+	// Horizon is the forecast time-to-OOM below which a warning is sent; it defaults to 10 minutes
+	Horizon time.Duration
+}
+
+// memoryHistory holds the recent samples of a watched process
+type memoryHistory struct {
+	startTime uint64
+	times     []float64
+	memory    []float64
+	oomKills  uint64
+}
+
+// WatchMemory samples the memory of matching processes every interval and sends a
+// forecast when a process is expected to run out of memory within the horizon, or when
+// the OOM killer has killed something in its cgroup. A process is reported again only
+// after its forecast recovers. The channel is closed when ctx is done.
+func WatchMemory(ctx context.Context, cfg MemoryWatchConfig) (<-chan MemoryForecast, error) {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second
+	}
+	if cfg.Window < 2 {
+		cfg.Window = 10
+	}
+	if cfg.Horizon <= 0 {
+		cfg.Horizon = 10 * time.Minute
+	}
+	if _, err := readKeyValues(procRoot + "/meminfo"); err != nil {
+		return nil, err
+	}
+
+	forecasts := make(chan MemoryForecast)
+	go func() {
+		defer close(forecasts)
+
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+
+		start := time.Now()
+		histories := make(map[int]*memoryHistory)
+		warned := make(map[int]bool)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				for _, f := range sampleMemory(cfg, histories, now.Sub(start).Seconds()) {
+					f.Time = now
+					risky := f.OOMKills > 0 || (f.TimeToOOM > 0 && f.TimeToOOM < cfg.Horizon)
+					if risky && !warned[f.ID] {
+						select {
+						case forecasts <- f:
+						case <-ctx.Done():
+							return
+						}
+					}
+					warned[f.ID] = risky
+				}
+				for pID := range warned {
+					if _, ok := histories[pID]; !ok {
+						delete(warned, pID)
+					}
+				}
+			}
+		}
+	}()
+	return forecasts, nil
+}
+
+// sampleMemory records a sample of every matching process in histories, dropping
+// processes that exited, and returns a forecast for each
+func sampleMemory(cfg MemoryWatchConfig, histories map[int]*memoryHistory, at float64) []MemoryForecast {
+	pIDs, err := listPIDs()
+	if err != nil {
+		return nil
+	}
+	meminfo, err := readKeyValues(procRoot + "/meminfo")
+	if err != nil {
+		return nil
+	}
+
+	seen := make(map[int]bool)
+	var results []MemoryForecast
+	for _, pID := range pIDs {
+		stat, err := readStat(pID)
+		if err != nil {
+			continue
+		}
+		cmdline, _ := readCmdline(pID)
+		if !matchesProcess(cfg.Match, stat.Comm, cmdline) {
+			continue
+		}
+
+		memory := uint64(stat.RSS) * pageSize
+		if rollup, err := readKeyValues(procPath(pID, "smaps_rollup")); err == nil {
+			memory = rollup["Pss"]
+		}
+
+		h, ok := histories[pID]
+		if !ok || h.startTime != stat.StartTime {
+			h = &memoryHistory{startTime: stat.StartTime, oomKills: cgroupOOMKills(pID)}
+			histories[pID] = h
+		}
+		h.times = append(h.times, at)
+		h.memory = append(h.memory, float64(memory))
+		if len(h.times) > cfg.Window {
+			h.times, h.memory = h.times[1:], h.memory[1:]
+		}
+		seen[pID] = true
+
+		f := MemoryForecast{
+			Name:         stat.Comm,
+			ID:           pID,
+			Identity:     processIdentity(stat.Comm, cmdline),
+			Memory:       memory,
+			Growth:       slope(h.times, h.memory),
+			MemAvailable: meminfo["MemAvailable"],
+			OOMKills:     cgroupOOMKills(pID) - h.oomKills,
+		}
+		f.CgroupHeadroom, f.CgroupLimited = cgroupMemoryHeadroom(pID)
+
+		headroom := f.MemAvailable
+		if f.CgroupLimited && f.CgroupHeadroom < headroom {
+			headroom = f.CgroupHeadroom
+		}
+		if f.Growth > 0 {
+			// a process already at its limit still has a nonzero forecast, as 0 means not growing
+			f.TimeToOOM = time.Duration(float64(headroom)/f.Growth*float64(time.Second)) + 1
+		}
+		results = append(results, f)
+	}
+
+	for pID := range histories {
+		if !seen[pID] {
+			delete(histories, pID)
+		}
+	}
+	return results
+}
+
+// slope returns the least-squares slope of ys over xs
+func slope(xs, ys []float64) float64 {
+	n := float64(len(xs))
+	if n < 2 {
+		return 0
+	}
+
+	var sumX, sumY, sumXY, sumXX float64
+	for i := range xs {
+		sumX += xs[i]
+		sumY += ys[i]
+		sumXY += xs[i] * ys[i]
+		sumXX += xs[i] * xs[i]
+	}
+	d := n*sumXX - sumX*sumX
+	if d == 0 {
+		return 0
+	}
+	return (n*sumXY - sumX*sumY) / d
+}
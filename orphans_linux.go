@@ -0,0 +1,119 @@
+package findprocess
+
+import (
+	"path"
+	"strings"
+)
+
+// OrphanedProcess contains details of a process that appears to have outlived the
+// service that started it
+type OrphanedProcess struct {
+	Name     string
+	ID       int
+	ParentID int
+	// Owner is the inferred original owner: a systemd unit name, or the prefix of a
+	// process title such as "nginx" for "nginx: worker process"
+	Owner  string
+	Cgroup string
+}
+
+// Orphans finds processes that were reparented to init or a systemd subreaper but
+// whose cgroup or cmdline shows they belong to a service.
+//
+// A service's main process leads its own session, as systemd and daemon(3) arrange, and
+// its workers inherit that session. A reparented process that leads neither its session
+// nor its process group, and whose session and group leaders have both exited, was left
+// behind by a main process that is gone. A daemon that forks a second time after setsid
+// looks the same, so its owner is reported for checking before cleanup.
+func Orphans() ([]OrphanedProcess, error) {
+	procs, err := processes()
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int]*LinuxProcess, len(procs))
+	for i := range procs {
+		byID[procs[i].ProcessID] = &procs[i]
+	}
+
+	var results []OrphanedProcess
+	for _, p := range procs {
+		if !isReparented(p, byID) {
+			continue
+		}
+
+		stat, err := readStat(p.ProcessID)
+		if err != nil || !leadersGone(stat) {
+			continue
+		}
+
+		cgroup, err := readCgroup(p.ProcessID)
+		if err != nil {
+			continue
+		}
+		owner := serviceUnit(cgroup)
+		if owner == "" {
+			cmdline, err := readCmdline(p.ProcessID)
+			if err != nil || len(cmdline) == 0 {
+				continue
+			}
+			owner = titleOwner(cmdline[0])
+		}
+		if owner == "" {
+			continue
+		}
+
+		results = append(results, OrphanedProcess{
+			Name:     p.Filename,
+			ID:       p.ProcessID,
+			ParentID: p.ParentID,
+			Owner:    owner,
+			Cgroup:   cgroup,
+		})
+	}
+	return results, nil
+}
+
+// leadersGone reports whether a process leads neither its session nor its process
+// group and the processes that did have exited. A leader that is a zombie has exited.
+func leadersGone(stat *procStat) bool {
+	if stat.PID == stat.Session || stat.PID == stat.PGRP || stat.Session == 0 {
+		return false
+	}
+	return !isLive(stat.Session) && !isLive(stat.PGRP)
+}
+
+func isLive(pID int) bool {
+	stat, err := readStat(pID)
+	return err == nil && stat.State != 'Z'
+}
+
+// isReparented reports whether a process's parent is init or a systemd user manager,
+// which adopt processes whose original parent exited
+func isReparented(p LinuxProcess, byID map[int]*LinuxProcess) bool {
+	if p.ParentID == 1 {
+		return true
+	}
+	parent, ok := byID[p.ParentID]
+	return ok && parent.Filename == "systemd"
+}
+
+// serviceUnit returns the systemd service unit a cgroup path belongs to, if any
+func serviceUnit(cgroup string) string {
+	for dir := cgroup; dir != "/" && dir != "." && dir != ""; dir = path.Dir(dir) {
+		if unit := path.Base(dir); strings.HasSuffix(unit, ".service") {
+			return unit
+		}
+	}
+	return ""
+}
+
+// titleOwner returns the owner named in a "owner: role" process title, as set by
+// nginx, postgres and similar pre-forking servers
+func titleOwner(argv0 string) string {
+	i := strings.Index(argv0, ": ")
+	if i <= 0 || strings.ContainsAny(argv0[:i], " /") {
+		return ""
+	}
+	return argv0[:i]
+}
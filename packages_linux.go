@@ -0,0 +1,287 @@
+package findprocess
+
+import (
+	"bufio"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// dpkgRoot is the directory of dpkg's database
+const dpkgRoot = "/var/lib/dpkg"
+
+// mergedDirs are the directories that /usr-merged systems link into /usr, so a file dpkg
+// installed as /bin/bash runs as /usr/bin/bash
+var mergedDirs = []string{"bin", "sbin", "lib", "lib32", "lib64", "libx32"}
+
+// PackageDB is an index of the files installed by dpkg in one mount namespace
+type PackageDB struct {
+	// dir is the dpkg database directory, through a process's root for a container
+	dir string
+	// mountNS identifies the mount namespace whose files the database describes
+	mountNS string
+	// owners maps an installed path to the package that installed it, as named by its
+	// files in the info directory, such as "bash" or "libc6:amd64"
+	owners map[string]string
+	// versions maps package names, with and without an architecture, to versions
+	versions map[string]string
+	// md5sums holds the recorded checksums of the packages read so far
+	md5sums map[string]map[string]string
+}
+
+// ExePackage contains the package that installed a process's executable
+type ExePackage struct {
+	Name string
+	ID   int
+	Exe  string
+	// Package and Version are empty if no installed package owns the executable
+	Package string
+	Version string
+	// Checked is true if dpkg recorded a checksum for the executable, and Modified is
+	// true if the running executable does not match it. An executable replaced by a
+	// package upgrade while it ran is also Modified.
+	Checked  bool
+	Modified bool
+}
+
+// LoadPackageDB reads the file lists and installed versions of the dpkg database in the
+// caller's mount namespace
+func LoadPackageDB() (*PackageDB, error) {
+	return loadPackageDB(os.Getpid())
+}
+
+// loadPackageDB reads the dpkg database a process sees, through its root directory so
+// that a container's own database is used for its processes
+func loadPackageDB(pID int) (*PackageDB, error) {
+	dir := procPath(pID, "root", dpkgRoot)
+	mountNS, err := os.Readlink(procPath(pID, "ns", "mnt"))
+	if err != nil {
+		return nil, err
+	}
+	lists, err := filepath.Glob(filepath.Join(dir, "info", "*.list"))
+	if err != nil {
+		return nil, err
+	}
+	if len(lists) == 0 {
+		return nil, errMalformed("dpkg database")
+	}
+
+	db := PackageDB{dir: dir, mountNS: mountNS, owners: make(map[string]string), md5sums: make(map[string]map[string]string)}
+	for _, list := range lists {
+		pkg := strings.TrimSuffix(filepath.Base(list), ".list")
+		data, err := os.ReadFile(list)
+		if err != nil {
+			continue
+		}
+		for _, p := range strings.Split(string(data), "\n") {
+			if p != "" {
+				db.owners[p] = pkg
+			}
+		}
+	}
+
+	if db.versions, err = readDpkgVersions(filepath.Join(dir, "status")); err != nil {
+		return nil, err
+	}
+	return &db, nil
+}
+
+// Owner returns the package that installed the file at path
+func (db *PackageDB) Owner(path string) (string, bool) {
+	for _, p := range packagePaths(path) {
+		if pkg, ok := db.owners[p]; ok {
+			return pkg, true
+		}
+	}
+	return "", false
+}
+
+// Process finds the package that installed the executable of the process with a given
+// pID and checks the running executable against dpkg's recorded MD5 checksum. The
+// process must be in the mount namespace the database was read from.
+func (db *PackageDB) Process(pID int) (*ExePackage, error) {
+	stat, err := readStat(pID)
+	if err != nil {
+		return nil, err
+	}
+	if ns, err := os.Readlink(procPath(pID, "ns", "mnt")); err != nil || ns != db.mountNS {
+		return nil, fmt.Errorf("findprocess: process %d is not in the dpkg database's mount namespace", pID)
+	}
+	exe, err := os.Readlink(procPath(pID, "exe"))
+	if err != nil {
+		return nil, err
+	}
+
+	e := ExePackage{Name: stat.Comm, ID: pID, Exe: exe}
+	path := strings.TrimSuffix(exe, " (deleted)")
+	pkg, ok := db.Owner(path)
+	if !ok {
+		return &e, nil
+	}
+	e.Package = pkg
+	e.Version = db.versions[pkg]
+	// the info files of Multi-Arch packages carry the architecture, as in "libc6:amd64"
+	if i := strings.IndexByte(pkg, ':'); i >= 0 {
+		e.Package = pkg[:i]
+	}
+
+	sums := db.packageMD5sums(pkg)
+	for _, p := range packagePaths(path) {
+		want, ok := sums[strings.TrimPrefix(p, "/")]
+		if !ok {
+			continue
+		}
+		got, err := fileMD5(procPath(pID, "exe"))
+		if err != nil {
+			return nil, err
+		}
+		e.Checked = true
+		e.Modified = got != want
+		break
+	}
+	return &e, nil
+}
+
+// ExePackages finds the package of every readable process's executable. A process in a
+// container is looked up in the container's own dpkg database. Kernel threads, processes
+// whose executable the caller may not read and processes in a mount namespace without a
+// dpkg database are left out.
+func ExePackages() ([]ExePackage, error) {
+	host, err := LoadPackageDB()
+	if err != nil {
+		return nil, err
+	}
+	dbs := map[string]*PackageDB{host.mountNS: host}
+	procs, err := processes()
+	if err != nil {
+		return nil, err
+	}
+
+	// a binary shared by many processes in a namespace is hashed once
+	type nsFile struct {
+		ns   string
+		file fileID
+	}
+	checked := make(map[nsFile]ExePackage)
+	var results []ExePackage
+	for _, p := range procs {
+		var st syscall.Stat_t
+		if syscall.Stat(procPath(p.ProcessID, "exe"), &st) != nil {
+			continue
+		}
+		ns, err := os.Readlink(procPath(p.ProcessID, "ns", "mnt"))
+		if err != nil {
+			continue
+		}
+		id := nsFile{ns, fileID{uint64(st.Dev), st.Ino}}
+		db, ok := dbs[ns]
+		if !ok {
+			// a namespace without a readable database is remembered as nil
+			db, _ = loadPackageDB(p.ProcessID)
+			dbs[ns] = db
+		}
+		if db == nil {
+			continue
+		}
+
+		e, ok := checked[id]
+		if !ok {
+			ep, err := db.Process(p.ProcessID)
+			if err != nil {
+				continue
+			}
+			e = *ep
+			checked[id] = e
+		}
+		e.Name, e.ID = p.Filename, p.ProcessID
+		results = append(results, e)
+	}
+	return results, nil
+}
+
+func (db *PackageDB) packageMD5sums(pkg string) map[string]string {
+	if sums, ok := db.md5sums[pkg]; ok {
+		return sums
+	}
+
+	sums := make(map[string]string)
+	if data, err := os.ReadFile(filepath.Join(db.dir, "info", pkg+".md5sums")); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			// checksum, two spaces, path relative to /
+			if fields := strings.SplitN(line, "  ", 2); len(fields) == 2 {
+				sums[fields[1]] = fields[0]
+			}
+		}
+	}
+	db.md5sums[pkg] = sums
+	return sums
+}
+
+// packagePaths returns the paths dpkg may have recorded a file under: the path itself
+// and, on /usr-merged systems, its location outside /usr
+func packagePaths(path string) []string {
+	paths := []string{path}
+	for _, dir := range mergedDirs {
+		if strings.HasPrefix(path, "/usr/"+dir+"/") {
+			paths = append(paths, strings.TrimPrefix(path, "/usr"))
+		}
+	}
+	return paths
+}
+
+// readDpkgVersions reads the versions of installed packages from dpkg's status file,
+// keyed by both "name" and "name:arch"
+func readDpkgVersions(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	versions := make(map[string]string)
+	var name, arch, version string
+	flush := func() {
+		if name != "" && version != "" {
+			versions[name] = version
+			versions[name+":"+arch] = version
+		}
+		name, arch, version = "", "", ""
+	}
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			flush()
+		case strings.HasPrefix(line, "Package: "):
+			name = strings.TrimPrefix(line, "Package: ")
+		case strings.HasPrefix(line, "Architecture: "):
+			arch = strings.TrimPrefix(line, "Architecture: ")
+		case strings.HasPrefix(line, "Version: "):
+			version = strings.TrimPrefix(line, "Version: ")
+		}
+	}
+	flush()
+	return versions, scanner.Err()
+}
+
+func fileMD5(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := md5.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
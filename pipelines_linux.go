@@ -0,0 +1,272 @@
+package findprocess
+
+import (
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// PipelineMember is a process in a pipeline
+type PipelineMember struct {
+	Name    string
+	ID      int
+	Cmdline []string
+	// StartTime is the process's start time in clock ticks since boot, as in KillTarget
+	StartTime uint64
+}
+
+// PipeConnection is a pipe that one process writes to and another reads from
+type PipeConnection struct {
+	// Inode identifies the pipe, as in the "pipe:[inode]" link of its file descriptors
+	Inode    uint64
+	WriterID int
+	ReaderID int
+}
+
+// Pipeline is a group of processes connected by pipes, such as `tar | gzip | ssh`
+type Pipeline struct {
+	// Members are ordered so that each process comes before the processes it writes to
+	Members     []PipelineMember
+	Connections []PipeConnection
+}
+
+// String renders the pipeline as a shell would, such as "tar -c . | gzip | ssh host"
+func (p *Pipeline) String() string {
+	commands := make([]string, len(p.Members))
+	for i, m := range p.Members {
+		commands[i] = quoteArgs(m.Cmdline)
+		if commands[i] == "" {
+			commands[i] = "[" + m.Name + "]"
+		}
+	}
+	return strings.Join(commands, " | ")
+}
+
+// Pipelines finds the groups of processes connected by pipes. The direction of each
+// pipe comes from the access mode its file descriptor was opened with, or when that is
+// unreadable from whether it is a process's stdin or stdout. A pipe only connects two
+// processes in the same process group, as a shell puts the commands of a pipeline, and
+// never a process and its parent, so a supervisor holding the pipes of its children's
+// output is not joined to them. A process's pipe to itself is ignored.
+func Pipelines() ([]Pipeline, error) {
+	pIDs, err := listPIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	type pipeEnds struct {
+		writers []int
+		readers []int
+	}
+	pipes := make(map[uint64]*pipeEnds)
+	stats := make(map[int]*procStat, len(pIDs))
+	for _, pID := range pIDs {
+		stat, err := readStat(pID)
+		if err != nil {
+			continue
+		}
+		stats[pID] = stat
+		for inode, write := range processPipes(pID) {
+			ends, ok := pipes[inode]
+			if !ok {
+				ends = &pipeEnds{}
+				pipes[inode] = ends
+			}
+			for _, w := range write {
+				if w {
+					ends.writers = append(ends.writers, pID)
+				} else {
+					ends.readers = append(ends.readers, pID)
+				}
+			}
+		}
+	}
+
+	// union-find over PIDs, joining the processes at the two ends of each pipe
+	parent := make(map[int]int)
+	var find func(pID int) int
+	find = func(pID int) int {
+		if p, ok := parent[pID]; ok && p != pID {
+			root := find(p)
+			parent[pID] = root
+			return root
+		}
+		parent[pID] = pID
+		return pID
+	}
+
+	seen := make(map[PipeConnection]bool)
+	var connections []PipeConnection
+	for inode, ends := range pipes {
+		for _, w := range ends.writers {
+			for _, r := range ends.readers {
+				c := PipeConnection{Inode: inode, WriterID: w, ReaderID: r}
+				if w == r || seen[c] || !samePipeline(stats[w], stats[r]) {
+					continue
+				}
+				seen[c] = true
+				connections = append(connections, c)
+				parent[find(w)] = find(r)
+			}
+		}
+	}
+
+	groups := make(map[int]*Pipeline)
+	for _, c := range connections {
+		root := find(c.WriterID)
+		p, ok := groups[root]
+		if !ok {
+			p = &Pipeline{}
+			groups[root] = p
+		}
+		p.Connections = append(p.Connections, c)
+	}
+
+	results := make([]Pipeline, 0, len(groups))
+	for _, p := range groups {
+		sort.Slice(p.Connections, func(i, j int) bool {
+			a, b := p.Connections[i], p.Connections[j]
+			if a.WriterID != b.WriterID {
+				return a.WriterID < b.WriterID
+			}
+			return a.ReaderID < b.ReaderID
+		})
+		for _, pID := range orderPipeline(p.Connections) {
+			cmdline, _ := readCmdline(pID)
+			p.Members = append(p.Members, PipelineMember{Name: stats[pID].Comm, ID: pID, Cmdline: cmdline, StartTime: stats[pID].StartTime})
+		}
+		results = append(results, *p)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Members[0].ID < results[j].Members[0].ID })
+	return results, nil
+}
+
+// PipelinesOf finds the pipelines with a member whose name or space-separated cmdline matches re
+func PipelinesOf(re *regexp.Regexp) ([]Pipeline, error) {
+	pipelines, err := Pipelines()
+	if err != nil {
+		return nil, err
+	}
+
+	var results []Pipeline
+	for _, p := range pipelines {
+		for _, m := range p.Members {
+			if matchesProcess(re, m.Name, m.Cmdline) {
+				results = append(results, p)
+				break
+			}
+		}
+	}
+	return results, nil
+}
+
+// TerminatePipeline terminates every member of a pipeline at once with TerminateAll, so
+// that no member is left blocked writing to or reading from one that has exited. A
+// member whose PID has been reused since the pipeline was found is not signalled.
+func TerminatePipeline(p Pipeline, grace time.Duration) []TerminateResult {
+	targets := make([]KillTarget, len(p.Members))
+	for i, m := range p.Members {
+		targets[i] = KillTarget{Name: m.Name, ID: m.ID, Cmdline: m.Cmdline, StartTime: m.StartTime}
+	}
+	return TerminateAll(targets, grace)
+}
+
+// samePipeline reports whether the processes at the two ends of a pipe can belong to one pipeline
+func samePipeline(a, b *procStat) bool {
+	return a.PGRP == b.PGRP && a.PPID != b.PID && b.PPID != a.PID
+}
+
+// processPipes returns the pipes a process has open, keyed by inode, with whether each
+// file descriptor on the pipe is its write end
+func processPipes(pID int) map[uint64][]bool {
+	entries, err := os.ReadDir(procPath(pID, "fd"))
+	if err != nil {
+		return nil
+	}
+
+	pipes := make(map[uint64][]bool)
+	for _, e := range entries {
+		target, err := os.Readlink(procPath(pID, "fd", e.Name()))
+		if err != nil || !strings.HasPrefix(target, "pipe:[") {
+			continue
+		}
+		inode, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(target, "pipe:["), "]"), 10, 64)
+		if err != nil {
+			continue
+		}
+		if write, ok := pipeFDWrites(pID, e.Name()); ok {
+			pipes[inode] = append(pipes[inode], write)
+		}
+	}
+	return pipes
+}
+
+// pipeFDWrites reports whether a file descriptor is the write end of a pipe from the
+// flags in its fdinfo, falling back on stdin being read and stdout and stderr written
+func pipeFDWrites(pID int, fd string) (write bool, ok bool) {
+	if data, err := os.ReadFile(procPath(pID, "fdinfo", fd)); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if !strings.HasPrefix(line, "flags:") {
+				continue
+			}
+			flags, err := strconv.ParseUint(strings.TrimSpace(strings.TrimPrefix(line, "flags:")), 8, 64)
+			if err != nil {
+				break
+			}
+			switch flags & syscall.O_ACCMODE {
+			case syscall.O_RDONLY:
+				return false, true
+			case syscall.O_WRONLY:
+				return true, true
+			}
+		}
+	}
+
+	switch fd {
+	case "0":
+		return false, true
+	case "1", "2":
+		return true, true
+	}
+	return false, false
+}
+
+// orderPipeline orders the processes of a pipeline so that writers come before the
+// processes they write to. Processes in a cycle, such as coprocesses, are ordered by PID.
+func orderPipeline(connections []PipeConnection) []int {
+	incoming := make(map[int]int)
+	outgoing := make(map[int][]int)
+	for _, c := range connections {
+		incoming[c.ReaderID]++
+		if _, ok := incoming[c.WriterID]; !ok {
+			incoming[c.WriterID] = 0
+		}
+		outgoing[c.WriterID] = append(outgoing[c.WriterID], c.ReaderID)
+	}
+
+	var order []int
+	for len(incoming) > 0 {
+		// the lowest PID with no remaining writers, or the lowest PID if all are in a cycle
+		next, cyclic := -1, true
+		for pID, n := range incoming {
+			if n == 0 && (cyclic || pID < next) {
+				next, cyclic = pID, false
+			} else if cyclic && (next < 0 || pID < next) {
+				next = pID
+			}
+		}
+
+		order = append(order, next)
+		delete(incoming, next)
+		for _, r := range outgoing[next] {
+			if _, ok := incoming[r]; ok {
+				incoming[r]--
+			}
+		}
+	}
+	return order
+}
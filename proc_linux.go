@@ -0,0 +1,386 @@
+package findprocess
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// procRoot is the mount point of the proc filesystem
+const procRoot = "/proc"
+
+// clockTicks is USER_HZ, the unit of the times in /proc/<pid>/stat. It is 100 on every Linux architecture.
+const clockTicks = 100
+
+// commLen is the length in bytes the kernel truncates a process's comm to
+const commLen = 15
+
+// pageSize is the size in bytes of the pages RSS is counted in
+var pageSize = uint64(os.Getpagesize())
+
+// procStat holds the fields of /proc/<pid>/stat that the package uses.
+// Times are in clock ticks and RSS is in pages, as reported by the kernel.
+type procStat struct {
+	PID        int
+	Comm       string
+	State      byte
+	PPID       int
+	PGRP       int
+	Session    int
+	UTime      uint64
+	STime      uint64
+	CUTime     uint64
+	CSTime     uint64
+	NumThreads int
+	StartTime  uint64
+	VSize      uint64
+	RSS        int64
+}
+
+func procPath(pID int, name ...string) string {
+	return filepath.Join(append([]string{procRoot, strconv.Itoa(pID)}, name...)...)
+}
+
+// listPIDs returns the IDs of every process currently visible in /proc
+func listPIDs() ([]int, error) {
+	entries, err := os.ReadDir(procRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	pIDs := make([]int, 0, len(entries))
+	for _, e := range entries {
+		pID, err := strconv.Atoi(e.Name())
+		if err != nil || !e.IsDir() {
+			continue
+		}
+		pIDs = append(pIDs, pID)
+	}
+	return pIDs, nil
+}
+
+func readStat(pID int) (*procStat, error) {
+	data, err := os.ReadFile(procPath(pID, "stat"))
+	if err != nil {
+		return nil, err
+	}
+	return parseStat(data)
+}
+
+func parseStat(data []byte) (*procStat, error) {
+	// comm is wrapped in parentheses and may itself contain spaces or parentheses
+	open := bytes.IndexByte(data, '(')
+	end := bytes.LastIndexByte(data, ')')
+	if open < 0 || end < open {
+		return nil, errMalformed("stat")
+	}
+
+	pID, err := strconv.Atoi(strings.TrimSpace(string(data[:open])))
+	if err != nil {
+		return nil, err
+	}
+
+	// fields[0] is the state, which is field 3 in proc(5)
+	fields := strings.Fields(string(data[end+1:]))
+	if len(fields) < 22 {
+		return nil, errMalformed("stat")
+	}
+
+	s := procStat{
+		PID:   pID,
+		Comm:  string(data[open+1 : end]),
+		State: fields[0][0],
+	}
+	s.PPID, _ = strconv.Atoi(fields[1])
+	s.PGRP, _ = strconv.Atoi(fields[2])
+	s.Session, _ = strconv.Atoi(fields[3])
+	s.UTime, _ = strconv.ParseUint(fields[11], 10, 64)
+	s.STime, _ = strconv.ParseUint(fields[12], 10, 64)
+	s.CUTime, _ = strconv.ParseUint(fields[13], 10, 64)
+	s.CSTime, _ = strconv.ParseUint(fields[14], 10, 64)
+	s.NumThreads, _ = strconv.Atoi(fields[17])
+	s.StartTime, _ = strconv.ParseUint(fields[19], 10, 64)
+	s.VSize, _ = strconv.ParseUint(fields[20], 10, 64)
+	s.RSS, _ = strconv.ParseInt(fields[21], 10, 64)
+
+	return &s, nil
+}
+
+// readCmdline returns the argument vector of a process. Kernel threads have an empty cmdline.
+func readCmdline(pID int) ([]string, error) {
+	data, err := os.ReadFile(procPath(pID, "cmdline"))
+	if err != nil {
+		return nil, err
+	}
+
+	data = bytes.TrimRight(data, "\x00")
+	if len(data) == 0 {
+		return nil, nil
+	}
+	return strings.Split(string(data), "\x00"), nil
+}
+
+// matchesProcess reports whether re matches a process's name or its space-separated
+// cmdline. A nil re matches every process. A name the kernel truncated is also matched
+// in full, as the basename of cmdline[0].
+func matchesProcess(re *regexp.Regexp, name string, cmdline []string) bool {
+	if re == nil || re.MatchString(name) || re.MatchString(strings.Join(cmdline, " ")) {
+		return true
+	}
+	if len(name) == commLen && len(cmdline) > 0 {
+		if full := untruncatedName(name, cmdline[0]); full != name {
+			return re.MatchString(full)
+		}
+	}
+	return false
+}
+
+// untruncatedName returns the first of paths whose basename starts with a comm the kernel
+// truncated to commLen bytes, or comm itself if none does
+func untruncatedName(comm string, paths ...string) string {
+	if len(comm) < commLen {
+		return comm
+	}
+	for _, p := range paths {
+		base := filepath.Base(strings.TrimSuffix(p, " (deleted)"))
+		if len(base) > len(comm) && strings.HasPrefix(base, comm) {
+			return base
+		}
+	}
+	return comm
+}
+
+// readCgroup returns the cgroup path of a process. The unified (v2) hierarchy is
+// preferred; on hybrid hosts the name=systemd hierarchy carries the unit layout instead.
+func readCgroup(pID int) (string, error) {
+	data, err := os.ReadFile(procPath(pID, "cgroup"))
+	if err != nil {
+		return "", err
+	}
+
+	var unified, named string
+	for _, line := range strings.Split(string(data), "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		switch {
+		case parts[0] == "0" && parts[1] == "":
+			unified = parts[2]
+		case parts[1] == "name=systemd":
+			named = parts[2]
+		}
+	}
+
+	if unified != "" && (unified != "/" || named == "") {
+		return unified, nil
+	}
+	return named, nil
+}
+
+type errMalformed string
+
+func (e errMalformed) Error() string {
+	return "findprocess: malformed /proc " + string(e) + " file"
+}
+
+// readStatus returns the key/value pairs of /proc/<pid>/status
+func readStatus(pID int) (map[string]string, error) {
+	data, err := os.ReadFile(procPath(pID, "status"))
+	if err != nil {
+		return nil, err
+	}
+
+	status := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		i := strings.IndexByte(line, ':')
+		if i < 0 {
+			continue
+		}
+		status[line[:i]] = strings.TrimSpace(line[i+1:])
+	}
+	return status, nil
+}
+
+// statusID returns the first (real) ID of a Uid or Gid line from /proc/<pid>/status
+func statusID(status map[string]string, key string) (int, bool) {
+	fields := strings.Fields(status[key])
+	if len(fields) == 0 {
+		return 0, false
+	}
+	id, err := strconv.Atoi(fields[0])
+	return id, err == nil
+}
+
+// Limit contains one resource limit of a process. Unlimited values are reported as "unlimited".
+type Limit struct {
+	Name  string
+	Soft  string
+	Hard  string
+	Units string
+}
+
+// readLimits returns the resource limits of a process from /proc/<pid>/limits
+func readLimits(pID int) ([]Limit, error) {
+	data, err := os.ReadFile(procPath(pID, "limits"))
+	if err != nil {
+		return nil, err
+	}
+
+	var limits []Limit
+	for i, line := range strings.Split(string(data), "\n") {
+		// the name column is fixed width and contains spaces; the header is skipped
+		if i == 0 || len(line) < 26 {
+			continue
+		}
+		fields := strings.Fields(line[26:])
+		if len(fields) < 2 {
+			continue
+		}
+		l := Limit{Name: strings.TrimSpace(line[:26]), Soft: fields[0], Hard: fields[1]}
+		if len(fields) > 2 {
+			l.Units = fields[2]
+		}
+		limits = append(limits, l)
+	}
+	return limits, nil
+}
+
+// readKeyValues parses a /proc file of "Key: value [unit]" lines, such as io or smaps_rollup,
+// returning the numeric value of each key. Values in kB are converted to bytes.
+func readKeyValues(path string) (map[string]uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]uint64)
+	for _, line := range strings.Split(string(data), "\n") {
+		i := strings.IndexByte(line, ':')
+		if i < 0 {
+			continue
+		}
+		fields := strings.Fields(line[i+1:])
+		if len(fields) == 0 {
+			continue
+		}
+		n, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		if len(fields) > 1 && fields[1] == "kB" {
+			n *= 1024
+		}
+		values[line[:i]] = n
+	}
+	return values, nil
+}
+
+// countFDs returns the number of open file descriptors of a process
+func countFDs(pID int) (int, error) {
+	entries, err := os.ReadDir(procPath(pID, "fd"))
+	if err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
+
+// mapsEntry is a mapping listed in /proc/<pid>/maps
+type mapsEntry struct {
+	// addresses is the "start-end" range, which names the mapping in map_files
+	addresses string
+	start     uint64
+	end       uint64
+	perms     string
+	file      fileID
+	// path is empty for anonymous mappings and ends in " (deleted)" if the file was removed
+	path string
+}
+
+// readMaps returns the mappings of a process
+func readMaps(pID int) ([]mapsEntry, error) {
+	data, err := os.ReadFile(procPath(pID, "maps"))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []mapsEntry
+	for _, line := range strings.Split(string(data), "\n") {
+		if m, ok := parseMapsLine(line); ok {
+			entries = append(entries, m)
+		}
+	}
+	return entries, nil
+}
+
+// parseMapsLine parses a maps line of "address perms offset dev inode path", where the
+// path is padded with spaces and may itself contain spaces
+func parseMapsLine(line string) (mapsEntry, bool) {
+	fields := strings.SplitN(line, " ", 6)
+	if len(fields) < 5 {
+		return mapsEntry{}, false
+	}
+	bounds := strings.SplitN(fields[0], "-", 2)
+	if len(bounds) != 2 {
+		return mapsEntry{}, false
+	}
+
+	m := mapsEntry{addresses: fields[0], perms: fields[1]}
+	m.start, _ = strconv.ParseUint(bounds[0], 16, 64)
+	m.end, _ = strconv.ParseUint(bounds[1], 16, 64)
+	ino, _ := strconv.ParseUint(fields[4], 10, 64)
+	m.file = fileID{mapsDevice(fields[3]), ino}
+	if len(fields) == 6 {
+		m.path = strings.TrimLeft(fields[5], " ")
+	}
+	return m, true
+}
+
+// mapsDevice converts a maps "major:minor" device to the encoding used by stat
+func mapsDevice(dev string) uint64 {
+	parts := strings.SplitN(dev, ":", 2)
+	if len(parts) != 2 {
+		return 0
+	}
+	major, _ := strconv.ParseUint(parts[0], 16, 32)
+	minor, _ := strconv.ParseUint(parts[1], 16, 32)
+	return (minor & 0xff) | (major&0xfff)<<8 | (minor&^0xff)<<12 | (major&^0xfff)<<32
+}
+
+// uptime returns the time since boot in seconds
+func uptime() (float64, error) {
+	data, err := os.ReadFile(procRoot + "/uptime")
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, errMalformed("uptime")
+	}
+	return strconv.ParseFloat(fields[0], 64)
+}
+
+// parseCPUList parses a kernel CPU or node list such as "0-3,8" into its members
+func parseCPUList(list string) []int {
+	var members []int
+	for _, r := range strings.Split(strings.TrimSpace(list), ",") {
+		bounds := strings.SplitN(r, "-", 2)
+		lo, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			continue
+		}
+		hi := lo
+		if len(bounds) == 2 {
+			if hi, err = strconv.Atoi(bounds[1]); err != nil {
+				continue
+			}
+		}
+		for i := lo; i <= hi; i++ {
+			members = append(members, i)
+		}
+	}
+	return members
+}
@@ -0,0 +1,251 @@
+package findprocess
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sample contains the metrics of one process at one point in time
+type Sample struct {
+	Time time.Time `json:"time"`
+	// Identity is stable across restarts of the same command: it is derived from the
+	// process name and cmdline, so a restarted process continues the same series
+	Identity string `json:"identity"`
+	PID      int    `json:"pid"`
+	Name     string `json:"name"`
+	// User, Cgroup and Unit attribute the process for chargeback: the real user, the
+	// cgroup path and the systemd service unit that cgroup belongs to, if any
+	User   string `json:"user"`
+	Cgroup string `json:"cgroup"`
+	Unit   string `json:"unit,omitempty"`
+	// CPUPercent is the CPU time used since the previous sample as a percentage of one
+	// CPU; for the first sample of a process it is averaged over the process's lifetime.
+	// I/O rates are measured the same way, and are 0 when the process's io counters could
+	// not be read for this sample or the previous one.
+	CPUPercent       float64 `json:"cpu_percent"`
+	RSS              uint64  `json:"rss_bytes"`
+	PSS              uint64  `json:"pss_bytes"`
+	ReadBytesPerSec  float64 `json:"read_bytes_per_sec"`
+	WriteBytesPerSec float64 `json:"write_bytes_per_sec"`
+	FDs              int     `json:"fds"`
+	Threads          int     `json:"threads"`
+}
+
+// SampleWriter stores the samples taken by a Recorder
+type SampleWriter interface {
+	// WriteSamples is called once per interval with every sample taken in it
+	WriteSamples(samples []Sample) error
+}
+
+// RecorderConfig configures a Recorder
+type RecorderConfig struct {
+	// Match selects the processes to record by their name or space-separated cmdline
+	Match *regexp.Regexp
+	// Interval is the time between samples; it defaults to a second
+	Interval time.Duration
+}
+
+// Recorder periodically samples the metrics of matching processes. Processes that start
+// while it runs are picked up on the next interval and processes that exit are dropped.
+type Recorder struct {
+	cfg      RecorderConfig
+	w        SampleWriter
+	previous map[int]recordedProcess
+}
+
+// recordedProcess contains the counters of a process at its previous sample
+type recordedProcess struct {
+	startTime  uint64
+	cpuTicks   uint64
+	readBytes  uint64
+	writeBytes uint64
+	// hasIO is true if readBytes and writeBytes were read, which needs the caller to be
+	// allowed to ptrace the process
+	hasIO bool
+	at    float64
+}
+
+// NewRecorder creates a Recorder that writes its samples to w
+func NewRecorder(w SampleWriter, cfg RecorderConfig) *Recorder {
+	return &Recorder{cfg: cfg, w: w, previous: make(map[int]recordedProcess)}
+}
+
+// Run records samples every interval until ctx is done or writing fails
+func (r *Recorder) Run(ctx context.Context) error {
+	interval := r.cfg.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.Record(); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Record takes one sample of every matching process and writes them
+func (r *Recorder) Record() error {
+	samples, err := r.sample()
+	if err != nil {
+		return err
+	}
+	return r.w.WriteSamples(samples)
+}
+
+func (r *Recorder) sample() ([]Sample, error) {
+	now := time.Now()
+	up, err := uptime()
+	if err != nil {
+		return nil, err
+	}
+	pIDs, err := listPIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	current := make(map[int]recordedProcess)
+	var samples []Sample
+	for _, pID := range pIDs {
+		stat, err := readStat(pID)
+		if err != nil {
+			continue
+		}
+		cmdline, _ := readCmdline(pID)
+		if !matchesProcess(r.cfg.Match, stat.Comm, cmdline) {
+			continue
+		}
+
+		rp := recordedProcess{startTime: stat.StartTime, cpuTicks: stat.UTime + stat.STime, at: up}
+		if counters, err := readKeyValues(procPath(pID, "io")); err == nil {
+			rp.readBytes, rp.writeBytes, rp.hasIO = counters["read_bytes"], counters["write_bytes"], true
+		}
+
+		// a PID that was reused by a new process is measured from its start
+		prev, ok := r.previous[pID]
+		if !ok || prev.startTime != stat.StartTime {
+			prev = recordedProcess{startTime: stat.StartTime, hasIO: true, at: float64(stat.StartTime) / clockTicks}
+		}
+		elapsed := up - prev.at
+		if elapsed <= 0 {
+			elapsed = 1.0 / clockTicks
+		}
+
+		s := Sample{
+			Time:       now,
+			Identity:   processIdentity(stat.Comm, cmdline),
+			PID:        pID,
+			Name:       stat.Comm,
+			CPUPercent: 100 * float64(rp.cpuTicks-prev.cpuTicks) / clockTicks / elapsed,
+			RSS:        uint64(stat.RSS) * pageSize,
+			Threads:    stat.NumThreads,
+		}
+		// a missing reading would otherwise wrap around to a huge rate
+		if rp.hasIO && prev.hasIO && rp.readBytes >= prev.readBytes && rp.writeBytes >= prev.writeBytes {
+			s.ReadBytesPerSec = float64(rp.readBytes-prev.readBytes) / elapsed
+			s.WriteBytesPerSec = float64(rp.writeBytes-prev.writeBytes) / elapsed
+		}
+		if rollup, err := readKeyValues(procPath(pID, "smaps_rollup")); err == nil {
+			s.PSS = rollup["Pss"]
+		}
+		s.FDs, _ = countFDs(pID)
+		if status, err := readStatus(pID); err == nil {
+			if uID, ok := statusID(status, "Uid"); ok {
+				s.User = userName(uID)
+			}
+		}
+		if s.Cgroup, err = readCgroup(pID); err == nil {
+			s.Unit = serviceUnit(s.Cgroup)
+		}
+
+		samples = append(samples, s)
+		current[pID] = rp
+	}
+
+	r.previous = current
+	return samples, nil
+}
+
+// processIdentity names a process by its name and a short hash of its cmdline, which
+// stays the same when the process is restarted with the same command
+func processIdentity(name string, cmdline []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(cmdline, "\x00")))
+	return name + "-" + hex.EncodeToString(sum[:4])
+}
+
+// CSVSampleWriter writes samples as CSV with a header row
+type CSVSampleWriter struct {
+	w           *csv.Writer
+	wroteHeader bool
+}
+
+// NewCSVSampleWriter creates a CSVSampleWriter that writes to w
+func NewCSVSampleWriter(w io.Writer) *CSVSampleWriter {
+	return &CSVSampleWriter{w: csv.NewWriter(w)}
+}
+
+// WriteSamples writes one CSV row per sample
+func (c *CSVSampleWriter) WriteSamples(samples []Sample) error {
+	if !c.wroteHeader {
+		c.w.Write([]string{"time", "identity", "pid", "name", "user", "cgroup", "unit", "cpu_percent", "rss_bytes", "pss_bytes", "read_bytes_per_sec", "write_bytes_per_sec", "fds", "threads"})
+		c.wroteHeader = true
+	}
+
+	for _, s := range samples {
+		c.w.Write([]string{
+			s.Time.Format(time.RFC3339Nano),
+			s.Identity,
+			strconv.Itoa(s.PID),
+			s.Name,
+			s.User,
+			s.Cgroup,
+			s.Unit,
+			strconv.FormatFloat(s.CPUPercent, 'f', 2, 64),
+			strconv.FormatUint(s.RSS, 10),
+			strconv.FormatUint(s.PSS, 10),
+			strconv.FormatFloat(s.ReadBytesPerSec, 'f', 0, 64),
+			strconv.FormatFloat(s.WriteBytesPerSec, 'f', 0, 64),
+			strconv.Itoa(s.FDs),
+			strconv.Itoa(s.Threads),
+		})
+	}
+
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// JSONSampleWriter writes samples as JSON lines, one object per sample
+type JSONSampleWriter struct {
+	enc *json.Encoder
+}
+
+// NewJSONSampleWriter creates a JSONSampleWriter that writes to w
+func NewJSONSampleWriter(w io.Writer) *JSONSampleWriter {
+	return &JSONSampleWriter{enc: json.NewEncoder(w)}
+}
+
+// WriteSamples writes one line per sample
+func (j *JSONSampleWriter) WriteSamples(samples []Sample) error {
+	for _, s := range samples {
+		if err := j.enc.Encode(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package findprocess
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CPUTimes contains the time all CPUs have spent in each state since boot, from the
+// cpu line of /proc/stat
+type CPUTimes struct {
+	User    time.Duration
+	Nice    time.Duration
+	System  time.Duration
+	Idle    time.Duration
+	IOWait  time.Duration
+	IRQ     time.Duration
+	SoftIRQ time.Duration
+	Steal   time.Duration
+}
+
+// Total returns the sum of the times in every state
+func (c CPUTimes) Total() time.Duration {
+	return c.User + c.Nice + c.System + c.Idle + c.IOWait + c.IRQ + c.SoftIRQ + c.Steal
+}
+
+// Busy returns the time spent in any state other than idle and waiting on I/O
+func (c CPUTimes) Busy() time.Duration {
+	return c.Total() - c.Idle - c.IOWait
+}
+
+// SystemSnapshot contains host-wide statistics
+type SystemSnapshot struct {
+	Time        time.Time
+	LoadAverage [3]float64
+	CPU         CPUTimes
+	// Meminfo maps each /proc/meminfo field, such as "MemAvailable", to its value in bytes
+	Meminfo  map[string]uint64
+	Uptime   time.Duration
+	BootTime time.Time
+	// Running and Blocked are the numbers of tasks runnable and blocked on I/O
+	Running int
+	Blocked int
+	// Forks is the number of forks since boot
+	Forks uint64
+}
+
+// CPUUsage returns the percentage of all CPUs that was busy between an earlier snapshot and this one
+func (s *SystemSnapshot) CPUUsage(earlier *SystemSnapshot) float64 {
+	total := s.CPU.Total() - earlier.CPU.Total()
+	if total <= 0 {
+		return 0
+	}
+	return 100 * float64(s.CPU.Busy()-earlier.CPU.Busy()) / float64(total)
+}
+
+// Snapshot contains the process table along with the state of the host it was read from
+type Snapshot struct {
+	System    SystemSnapshot
+	Processes []LinuxProcess
+}
+
+// TakeSystemSnapshot reads the host-wide statistics
+func TakeSystemSnapshot() (*SystemSnapshot, error) {
+	s := SystemSnapshot{Time: time.Now()}
+
+	stat, err := readProcStat()
+	if err != nil {
+		return nil, err
+	}
+	s.CPU, s.BootTime, s.Running, s.Blocked, s.Forks = stat.cpu, stat.bootTime, stat.running, stat.blocked, stat.forks
+
+	if s.Meminfo, err = readKeyValues(procRoot + "/meminfo"); err != nil {
+		return nil, err
+	}
+
+	up, err := uptime()
+	if err != nil {
+		return nil, err
+	}
+	s.Uptime = time.Duration(up * float64(time.Second))
+
+	data, err := os.ReadFile(procRoot + "/loadavg")
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 3 {
+		return nil, errMalformed("loadavg")
+	}
+	for i := range s.LoadAverage {
+		s.LoadAverage[i], _ = strconv.ParseFloat(fields[i], 64)
+	}
+
+	return &s, nil
+}
+
+// TakeSnapshot reads the process table and the host-wide statistics together. /proc
+// cannot be read atomically, so the system statistics are read immediately before the
+// process table to keep the two as close in time as possible.
+func TakeSnapshot() (*Snapshot, error) {
+	system, err := TakeSystemSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	procs, err := processes()
+	if err != nil {
+		return nil, err
+	}
+	return &Snapshot{System: *system, Processes: procs}, nil
+}
+
+// procStatSummary holds the fields of /proc/stat that the package uses
+type procStatSummary struct {
+	cpu      CPUTimes
+	bootTime time.Time
+	running  int
+	blocked  int
+	forks    uint64
+	// hasForks is true if the processes line was found and parsed
+	hasForks bool
+}
+
+func readProcStat() (*procStatSummary, error) {
+	f, err := os.Open(procRoot + "/stat")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var s procStatSummary
+	scanner := bufio.NewScanner(f)
+	// the intr line has a count for every interrupt, which exceeds the default limit on large hosts
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "cpu":
+			s.cpu = parseCPUTimes(fields[1:])
+		case "btime":
+			secs, _ := strconv.ParseInt(fields[1], 10, 64)
+			s.bootTime = time.Unix(secs, 0)
+		case "procs_running":
+			s.running, _ = strconv.Atoi(fields[1])
+		case "procs_blocked":
+			s.blocked, _ = strconv.Atoi(fields[1])
+		case "processes":
+			forks, err := strconv.ParseUint(fields[1], 10, 64)
+			s.forks, s.hasForks = forks, err == nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func parseCPUTimes(fields []string) CPUTimes {
+	ticks := make([]time.Duration, 8)
+	for i := range ticks {
+		if i < len(fields) {
+			n, _ := strconv.ParseUint(fields[i], 10, 64)
+			ticks[i] = ticksToDuration(n)
+		}
+	}
+	return CPUTimes{
+		User:    ticks[0],
+		Nice:    ticks[1],
+		System:  ticks[2],
+		Idle:    ticks[3],
+		IOWait:  ticks[4],
+		IRQ:     ticks[5],
+		SoftIRQ: ticks[6],
+		Steal:   ticks[7],
+	}
+}
+
+// ticksToDuration converts a time in clock ticks to a Duration
+func ticksToDuration(ticks uint64) time.Duration {
+	return time.Duration(ticks) * time.Second / clockTicks
+}
@@ -0,0 +1,255 @@
+package findprocess
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ProcessSelector matches processes by name, pattern and user. Empty fields match every process.
+type ProcessSelector struct {
+	// Name is matched against the whole process name, like ByName
+	Name string
+	// Match is matched against the process name or space-separated cmdline
+	Match *regexp.Regexp
+	// User is a user name or numeric UID matched against the process's real user
+	User string
+}
+
+// String describes the selector for violation messages
+func (s ProcessSelector) String() string {
+	var parts []string
+	if s.Name != "" {
+		parts = append(parts, s.Name)
+	}
+	if s.Match != nil {
+		parts = append(parts, "/"+s.Match.String()+"/")
+	}
+	if s.User != "" {
+		parts = append(parts, "owned by "+s.User)
+	}
+	if len(parts) == 0 {
+		return "any process"
+	}
+	return strings.Join(parts, " ")
+}
+
+// ChildExpectation describes how many processes matching a selector a root must have beneath it
+type ChildExpectation struct {
+	Selector ProcessSelector
+	Min      int
+	// Max is the largest number allowed, or 0 for no upper bound
+	Max int
+	// Descendants counts matches anywhere beneath the root rather than only direct children
+	Descendants bool
+	// Exclusive requires every matching process in the snapshot to descend from a root,
+	// such as "postgres children must all descend from the postmaster"
+	Exclusive bool
+}
+
+// TopologyExpectation describes the structure expected of a process tree. Roots are the
+// processes matching Root whose parent does not also match it, so a master process is
+// a root but its same-named workers are not.
+type TopologyExpectation struct {
+	Description string
+	Root        ProcessSelector
+	MinRoots    int
+	// MaxRoots is the largest number of roots allowed, or 0 for no upper bound
+	MaxRoots int
+	Children []ChildExpectation
+}
+
+// ProcessNode is a process and its descendants within a snapshot
+type ProcessNode struct {
+	Process  LinuxProcess
+	Children []*ProcessNode
+}
+
+// String renders the subtree with one indented line per process
+func (n *ProcessNode) String() string {
+	var b strings.Builder
+	var write func(n *ProcessNode, depth int)
+	write = func(n *ProcessNode, depth int) {
+		fmt.Fprintf(&b, "%s%s (%d)\n", strings.Repeat("  ", depth), n.Process.Filename, n.Process.ProcessID)
+		for _, c := range n.Children {
+			write(c, depth+1)
+		}
+	}
+	write(n, 0)
+	return b.String()
+}
+
+// TopologyViolation is a failed topology expectation
+type TopologyViolation struct {
+	Description string
+	Message     string
+	// Subtree is the offending process tree, or nil if the violation is that a process is missing
+	Subtree *ProcessNode
+}
+
+// CheckTopology evaluates expectations against the parent/child relationships of one
+// snapshot and returns every violation. Users and cmdlines are read from /proc when a
+// selector needs them.
+func CheckTopology(s *Snapshot, expectations ...TopologyExpectation) []TopologyViolation {
+	t := newProcessTree(s.Processes)
+
+	var violations []TopologyViolation
+	for _, e := range expectations {
+		violations = append(violations, t.check(e)...)
+	}
+	return violations
+}
+
+// processTree indexes a snapshot for topology checks
+type processTree struct {
+	nodes map[int]*ProcessNode
+	// matches caches selector results by selector description and PID
+	matches map[string]map[int]bool
+}
+
+func newProcessTree(procs []LinuxProcess) *processTree {
+	t := processTree{nodes: make(map[int]*ProcessNode, len(procs)), matches: make(map[string]map[int]bool)}
+	for _, p := range procs {
+		t.nodes[p.ProcessID] = &ProcessNode{Process: p}
+	}
+	for _, n := range t.nodes {
+		if parent, ok := t.nodes[n.Process.ParentID]; ok && parent != n {
+			parent.Children = append(parent.Children, n)
+		}
+	}
+	for _, n := range t.nodes {
+		sort.Slice(n.Children, func(i, j int) bool { return n.Children[i].Process.ProcessID < n.Children[j].Process.ProcessID })
+	}
+	return &t
+}
+
+func (t *processTree) check(e TopologyExpectation) []TopologyViolation {
+	var violations []TopologyViolation
+	violate := func(subtree *ProcessNode, format string, args ...interface{}) {
+		violations = append(violations, TopologyViolation{Description: e.Description, Message: fmt.Sprintf(format, args...), Subtree: subtree})
+	}
+
+	var roots []*ProcessNode
+	for _, n := range t.sorted() {
+		if !t.match(e.Root, n) {
+			continue
+		}
+		if parent, ok := t.nodes[n.Process.ParentID]; ok && t.match(e.Root, parent) {
+			continue
+		}
+		roots = append(roots, n)
+	}
+
+	if len(roots) < e.MinRoots {
+		violate(nil, "found %d %s, expected at least %d", len(roots), e.Root, e.MinRoots)
+	}
+	if e.MaxRoots > 0 && len(roots) > e.MaxRoots {
+		for _, r := range roots {
+			violate(r, "found %d %s, expected at most %d", len(roots), e.Root, e.MaxRoots)
+		}
+	}
+
+	inRoot := make(map[int]bool)
+	for _, r := range roots {
+		for _, c := range e.Children {
+			var count int
+			candidates := r.Children
+			if c.Descendants {
+				candidates = descendants(r)
+			}
+			for _, n := range candidates {
+				if t.match(c.Selector, n) {
+					count++
+				}
+			}
+
+			scope := "children"
+			if c.Descendants {
+				scope = "descendants"
+			}
+			if count < c.Min {
+				violate(r, "%s (%d) has %d %s %s, expected at least %d", r.Process.Filename, r.Process.ProcessID, count, c.Selector, scope, c.Min)
+			}
+			if c.Max > 0 && count > c.Max {
+				violate(r, "%s (%d) has %d %s %s, expected at most %d", r.Process.Filename, r.Process.ProcessID, count, c.Selector, scope, c.Max)
+			}
+		}
+		for _, n := range descendants(r) {
+			inRoot[n.Process.ProcessID] = true
+		}
+		inRoot[r.Process.ProcessID] = true
+	}
+
+	for _, c := range e.Children {
+		if !c.Exclusive {
+			continue
+		}
+		for _, n := range t.sorted() {
+			if !inRoot[n.Process.ProcessID] && t.match(c.Selector, n) {
+				violate(n, "%s (%d) matches %s but does not descend from %s", n.Process.Filename, n.Process.ProcessID, c.Selector, e.Root)
+			}
+		}
+	}
+
+	return violations
+}
+
+// sorted returns every node in PID order so violations are reported deterministically
+func (t *processTree) sorted() []*ProcessNode {
+	nodes := make([]*ProcessNode, 0, len(t.nodes))
+	for _, n := range t.nodes {
+		nodes = append(nodes, n)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Process.ProcessID < nodes[j].Process.ProcessID })
+	return nodes
+}
+
+func (t *processTree) match(s ProcessSelector, n *ProcessNode) bool {
+	key := fmt.Sprintf("%q %v %q", s.Name, s.Match, s.User)
+	cache, ok := t.matches[key]
+	if !ok {
+		cache = make(map[int]bool)
+		t.matches[key] = cache
+	}
+	if m, ok := cache[n.Process.ProcessID]; ok {
+		return m
+	}
+
+	m := selectorMatches(s, n.Process)
+	cache[n.Process.ProcessID] = m
+	return m
+}
+
+func selectorMatches(s ProcessSelector, p LinuxProcess) bool {
+	if s.Name != "" && p.Filename != s.Name {
+		return false
+	}
+	if s.Match != nil {
+		cmdline, _ := readCmdline(p.ProcessID)
+		if !matchesProcess(s.Match, p.Filename, cmdline) {
+			return false
+		}
+	}
+	if s.User != "" {
+		status, err := readStatus(p.ProcessID)
+		if err != nil {
+			return false
+		}
+		uID, ok := statusID(status, "Uid")
+		if !ok || (s.User != strconv.Itoa(uID) && s.User != userName(uID)) {
+			return false
+		}
+	}
+	return true
+}
+
+func descendants(n *ProcessNode) []*ProcessNode {
+	var results []*ProcessNode
+	for _, c := range n.Children {
+		results = append(results, c)
+		results = append(results, descendants(c)...)
+	}
+	return results
+}
@@ -0,0 +1,63 @@
+package findprocess
+
+import (
+	"fmt"
+	"time"
+)
+
+// ProcessTreeUsage contains the resources used by a process and all its live descendants
+type ProcessTreeUsage struct {
+	Name string
+	ID   int
+	// Processes is the number of live processes in the tree, including the root
+	Processes int
+	// CPUTime is the user and system time of the live processes
+	CPUTime time.Duration
+	// ReapedCPUTime is the user and system time of children that have exited and been
+	// waited for by a process in the tree
+	ReapedCPUTime time.Duration
+	RSS           uint64
+	// PSS divides shared pages between the processes sharing them, so unlike RSS it does
+	// not count a library mapped by every process in the tree more than once. It covers
+	// only the processes whose smaps_rollup was readable.
+	PSS uint64
+}
+
+// TotalCPUTime returns the CPU time of the live processes and their reaped children
+func (u *ProcessTreeUsage) TotalCPUTime() time.Duration {
+	return u.CPUTime + u.ReapedCPUTime
+}
+
+// TreeUsage sums the CPU time and memory of the process with a given rootPID and its live
+// descendants. A reaped child's time is counted in its parent's cutime and cstime, and a
+// live child's time is not counted there until it is reaped, so nothing is counted twice.
+func TreeUsage(rootPID int) (*ProcessTreeUsage, error) {
+	procs, err := processes()
+	if err != nil {
+		return nil, err
+	}
+
+	t := newProcessTree(procs)
+	root, ok := t.nodes[rootPID]
+	if !ok {
+		return nil, fmt.Errorf("findprocess: no process with PID %d", rootPID)
+	}
+
+	u := ProcessTreeUsage{Name: root.Process.Filename, ID: rootPID}
+	for _, n := range append([]*ProcessNode{root}, descendants(root)...) {
+		stat, err := readStat(n.Process.ProcessID)
+		if err != nil {
+			// exited since the process table was read
+			continue
+		}
+
+		u.Processes++
+		u.CPUTime += ticksToDuration(stat.UTime + stat.STime)
+		u.ReapedCPUTime += ticksToDuration(stat.CUTime + stat.CSTime)
+		u.RSS += uint64(stat.RSS) * pageSize
+		if rollup, err := readKeyValues(procPath(n.Process.ProcessID, "smaps_rollup")); err == nil {
+			u.PSS += rollup["Pss"]
+		}
+	}
+	return &u, nil
+}
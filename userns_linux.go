@@ -0,0 +1,156 @@
+package findprocess
+
+import (
+	"bufio"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+// UserView selects which side of a user namespace a user filter compares against
+type UserView int
+
+const (
+	// HostView compares the UID as seen from the caller's namespace, such as 100000
+	// for root in a rootless container
+	HostView UserView = iota
+	// NamespaceView compares the UID inside the process's own user namespace, such as 0
+	// for root in a rootless container
+	NamespaceView
+)
+
+// Credentials contains a process's real UID and GID on both sides of its user namespace
+type Credentials struct {
+	UID  int
+	GID  int
+	User string
+	// NamespaceUID and NamespaceGID are the IDs inside the process's user namespace, or -1 if unmapped
+	NamespaceUID  int
+	NamespaceGID  int
+	NamespaceUser string
+	// UserNamespace identifies the namespace, such as "user:[4026531837]"
+	UserNamespace string
+}
+
+// ProcessCredentials reads the credentials of the process with a given pID and translates
+// them into its user namespace using its uid_map and gid_map
+func ProcessCredentials(pID int) (*Credentials, error) {
+	status, err := readStatus(pID)
+	if err != nil {
+		return nil, err
+	}
+
+	c := Credentials{}
+	c.UID, _ = statusID(status, "Uid")
+	c.GID, _ = statusID(status, "Gid")
+	c.User = userName(c.UID)
+	c.NamespaceUID = mapToNamespace(pID, "uid_map", c.UID)
+	c.NamespaceGID = mapToNamespace(pID, "gid_map", c.GID)
+	c.UserNamespace, _ = os.Readlink(procPath(pID, "ns", "user"))
+
+	c.NamespaceUser = strconv.Itoa(c.NamespaceUID)
+	if name, ok := lookupPasswd(pID, c.NamespaceUID); ok {
+		c.NamespaceUser = name
+	}
+	return &c, nil
+}
+
+// ByUser finds the running processes whose real user is userName in the given view.
+// userName may be a numeric UID. In NamespaceView names are looked up in each
+// process's own /etc/passwd, so "root" finds the root of every rootless container.
+func ByUser(userName string, view UserView) ([]ProcessStatus, error) {
+	uID, err := strconv.Atoi(userName)
+	numeric := err == nil
+	if !numeric && view == HostView {
+		u, err := user.Lookup(userName)
+		if err != nil {
+			return nil, err
+		}
+		if uID, err = strconv.Atoi(u.Uid); err != nil {
+			return nil, err
+		}
+	}
+
+	procs, err := processes()
+	if err != nil {
+		return nil, err
+	}
+
+	var results []ProcessStatus
+	for _, p := range procs {
+		c, err := ProcessCredentials(p.ProcessID)
+		if err != nil {
+			continue
+		}
+
+		var match bool
+		switch {
+		case view == HostView:
+			match = c.UID == uID
+		case numeric:
+			match = c.NamespaceUID == uID
+		default:
+			match = c.NamespaceUser == userName
+		}
+		if match {
+			results = append(results, ProcessStatus{Name: p.Filename, ID: p.ProcessID, IsRunning: true})
+		}
+	}
+	return results, nil
+}
+
+// mapToNamespace translates an ID into a process's user namespace using one of its ID
+// maps, whose lines are "inside-start outside-start count". A process in the caller's
+// own user namespace sees the same IDs, but its maps describe the namespace's parent, so
+// the ID is returned unchanged.
+func mapToNamespace(pID int, mapFile string, id int) int {
+	if ns, err := os.Readlink(procPath(pID, "ns", "user")); err == nil {
+		if own, err := os.Readlink(procPath(os.Getpid(), "ns", "user")); err == nil && ns == own {
+			return id
+		}
+	}
+
+	data, err := os.ReadFile(procPath(pID, mapFile))
+	if err != nil {
+		return -1
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		inside, err1 := strconv.ParseInt(fields[0], 10, 64)
+		outside, err2 := strconv.ParseInt(fields[1], 10, 64)
+		count, err3 := strconv.ParseInt(fields[2], 10, 64)
+		if err1 != nil || err2 != nil || err3 != nil {
+			continue
+		}
+		if int64(id) >= outside && int64(id) < outside+count {
+			return int(int64(id) - outside + inside)
+		}
+	}
+	return -1
+}
+
+// lookupPasswd finds the name of a UID in the /etc/passwd seen by a process, which for
+// a containerised process is the container's own
+func lookupPasswd(pID int, uID int) (string, bool) {
+	f, err := os.Open(procPath(pID, "root", "etc", "passwd"))
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+
+	want := strconv.Itoa(uID)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// name:password:UID:GID:...
+		fields := strings.SplitN(scanner.Text(), ":", 4)
+		if len(fields) >= 3 && fields[2] == want {
+			return fields[0], true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,239 @@
+package findprocess
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// Manifest is a signed list of the SHA-256 hashes of executables and libraries that
+// are allowed to run
+type Manifest struct {
+	// hashes maps each allowed hash to the name it was listed with
+	hashes map[string]string
+}
+
+// ParseManifest checks the ed25519 signature of a manifest and parses it. The manifest
+// is in sha256sum format: a hexadecimal hash and an optional file name per line, with
+// blank lines and lines starting with # ignored. The signature covers the manifest's
+// exact bytes and may be raw or base64. No network access is needed.
+func ParseManifest(data, signature []byte, key ed25519.PublicKey) (*Manifest, error) {
+	sig, err := decodeSignature(signature)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != ed25519.PublicKeySize || !ed25519.Verify(key, data, sig) {
+		return nil, errors.New("findprocess: manifest signature is not valid")
+	}
+
+	m := Manifest{hashes: make(map[string]string)}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		hash := strings.ToLower(fields[0])
+		if b, err := hex.DecodeString(hash); err != nil || len(b) != 32 {
+			return nil, errMalformed("manifest")
+		}
+		// sha256sum marks binary mode files with a leading *
+		m.hashes[hash] = strings.TrimPrefix(strings.TrimSpace(strings.TrimPrefix(line, fields[0])), "*")
+	}
+	return &m, nil
+}
+
+// Allowed reports whether a hexadecimal SHA-256 hash is listed in the manifest
+func (m *Manifest) Allowed(sha256 string) bool {
+	_, ok := m.hashes[strings.ToLower(sha256)]
+	return ok
+}
+
+// ParsePublicKey reads an ed25519 public key in PEM, as written by openssl, or as 32 raw
+// or base64 bytes
+func ParsePublicKey(data []byte) (ed25519.PublicKey, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		if k, ok := key.(ed25519.PublicKey); ok {
+			return k, nil
+		}
+		return nil, errors.New("findprocess: public key is not ed25519")
+	}
+
+	if len(data) == ed25519.PublicKeySize {
+		return ed25519.PublicKey(data), nil
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, errMalformed("public key")
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+func decodeSignature(data []byte) ([]byte, error) {
+	if len(data) == ed25519.SignatureSize {
+		return data, nil
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return nil, errMalformed("signature")
+	}
+	return sig, nil
+}
+
+// UnverifiedFile is an executable or library whose hash is not in the manifest
+type UnverifiedFile struct {
+	Path string
+	// SHA256 is empty if the file could not be read, such as a deleted library
+	SHA256 string
+	// Replaced is true if the file at Path is no longer the one the process mapped, as
+	// after an upgrade or an attacker covering their tracks, and the mapped one could
+	// not be read
+	Replaced bool
+}
+
+// VerifyFailure is a process running an executable or library that is not in the manifest
+type VerifyFailure struct {
+	Name  string
+	ID    int
+	Files []UnverifiedFile
+}
+
+// VerifyReport is the result of checking every process against a manifest. Kernel
+// threads have no executable and are not counted.
+type VerifyReport struct {
+	Failures []VerifyFailure
+	// VerifiedProcesses is the number of processes whose files are all listed
+	VerifiedProcesses int
+	// UnreadableProcesses is the number of processes the caller may not inspect
+	UnreadableProcesses int
+}
+
+// Verify checks the executable and the executable mappings, such as shared libraries,
+// of every running process against a manifest. Files are hashed once per device and
+// inode however many processes map them.
+func Verify(m *Manifest) (*VerifyReport, error) {
+	procs, err := processes()
+	if err != nil {
+		return nil, err
+	}
+
+	v := verifier{manifest: m, hashes: make(map[fileID]string)}
+	report := VerifyReport{}
+	for _, p := range procs {
+		if p.ProcessID == 2 || p.ParentID == 2 {
+			continue
+		}
+
+		exe, err := os.Readlink(procPath(p.ProcessID, "exe"))
+		if err != nil {
+			report.UnreadableProcesses++
+			continue
+		}
+
+		var files []UnverifiedFile
+		var st syscall.Stat_t
+		if err := syscall.Stat(procPath(p.ProcessID, "exe"), &st); err != nil {
+			files = append(files, UnverifiedFile{Path: exe})
+		} else if f, ok := v.check(exe, fileID{uint64(st.Dev), st.Ino}, procPath(p.ProcessID, "exe")); !ok {
+			files = append(files, f)
+		}
+		for _, m := range executableMappings(p.ProcessID) {
+			if m.path == exe {
+				continue
+			}
+			if f, ok := v.checkMapping(p.ProcessID, m); !ok {
+				files = append(files, f)
+			}
+		}
+
+		if len(files) > 0 {
+			report.Failures = append(report.Failures, VerifyFailure{Name: p.Filename, ID: p.ProcessID, Files: files})
+		} else {
+			report.VerifiedProcesses++
+		}
+	}
+	return &report, nil
+}
+
+// fileID identifies a file by device and inode
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+type verifier struct {
+	manifest *Manifest
+	hashes   map[fileID]string
+}
+
+// check hashes the file with a given device and inode that a process sees as name,
+// opening it through path in the process's /proc directory
+func (v *verifier) check(name string, id fileID, path string) (UnverifiedFile, bool) {
+	hash, ok := v.hashes[id]
+	if !ok {
+		hash, _ = fileSHA256(path)
+		v.hashes[id] = hash
+	}
+	if hash == "" || !v.manifest.Allowed(hash) {
+		return UnverifiedFile{Path: name, SHA256: hash}, false
+	}
+	return UnverifiedFile{}, true
+}
+
+// checkMapping hashes the file behind a mapping. The mapped inode is read through
+// map_files where the caller may; otherwise the file now at the mapping's path is read
+// through the process's root, but only if it is still the inode that was mapped.
+func (v *verifier) checkMapping(pID int, m executableMapping) (UnverifiedFile, bool) {
+	if _, ok := v.hashes[m.file]; ok {
+		return v.check(m.path, m.file, "")
+	}
+	if f, err := os.Open(procPath(pID, "map_files", m.addresses)); err == nil {
+		f.Close()
+		return v.check(m.path, m.file, procPath(pID, "map_files", m.addresses))
+	}
+
+	path := procPath(pID, "root", m.path)
+	var st syscall.Stat_t
+	// only the inode is compared, as overlay filesystems report a different device in maps
+	if err := syscall.Stat(path, &st); err != nil || st.Ino != m.file.ino {
+		return UnverifiedFile{Path: m.path, Replaced: true}, false
+	}
+	return v.check(m.path, m.file, path)
+}
+
+// executableMapping is a file a process maps executable
+type executableMapping struct {
+	path string
+	// addresses is the mapping's "start-end" range, which names it in map_files
+	addresses string
+	file      fileID
+}
+
+// executableMappings returns the files a process maps executable, once each
+func executableMappings(pID int) []executableMapping {
+	maps, err := readMaps(pID)
+	if err != nil {
+		return nil
+	}
+
+	seen := make(map[fileID]bool)
+	var results []executableMapping
+	for _, e := range maps {
+		if !strings.Contains(e.perms, "x") || !strings.HasPrefix(e.path, "/") || seen[e.file] {
+			continue
+		}
+		seen[e.file] = true
+		results = append(results, executableMapping{path: strings.TrimSuffix(e.path, " (deleted)"), addresses: e.addresses, file: e.file})
+	}
+	return results
+}
@@ -0,0 +1,115 @@
+package findprocess
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"strings"
+	"testing"
+)
+
+const (
+	testHashA = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	testHashB = "486ea46224d1bb4fb680f34f7c9ad96a8f24ec88be73ea8e5a6c65260e9cb8a7"
+)
+
+func TestParseManifest(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manifest := []byte("# allowed binaries\n" + testHashA + "  /usr/bin/hello\n\n" + strings.ToUpper(testHashB) + " */usr/lib/libworld.so\n")
+	sig := ed25519.Sign(priv, manifest)
+	flipped := append([]byte(nil), manifest...)
+	flipped[len(flipped)-2] ^= 1
+	malformed := []byte(testHashA + "  /usr/bin/hello\nnot-a-hash /usr/bin/evil\n")
+
+	tests := []struct {
+		name      string
+		data      []byte
+		signature []byte
+		key       ed25519.PublicKey
+		wantErr   bool
+	}{
+		{"raw signature", manifest, sig, pub, false},
+		{"base64 signature", manifest, []byte(base64.StdEncoding.EncodeToString(sig) + "\n"), pub, false},
+		{"flipped manifest byte", flipped, sig, pub, true},
+		{"wrong key", manifest, sig, otherPub, true},
+		{"truncated signature", manifest, sig[:ed25519.SignatureSize-1], pub, true},
+		{"truncated base64 signature", manifest, []byte(base64.StdEncoding.EncodeToString(sig[:32])), pub, true},
+		{"short key", manifest, sig, pub[:16], true},
+		{"malformed hash line", malformed, ed25519.Sign(priv, malformed), pub, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := ParseManifest(tt.data, tt.signature, tt.key)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !m.Allowed(testHashA) || !m.Allowed(strings.ToUpper(testHashA)) || !m.Allowed(testHashB) {
+				t.Error("listed hash is not allowed")
+			}
+			// the * marking binary mode is not part of the name
+			if got := m.hashes[testHashB]; got != "/usr/lib/libworld.so" {
+				t.Errorf("binary mode name = %q, want /usr/lib/libworld.so", got)
+			}
+			if m.Allowed(strings.Repeat("0", 64)) {
+				t.Error("unlisted hash is allowed")
+			}
+		})
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{"raw", []byte(pub), false},
+		{"base64", []byte(base64.StdEncoding.EncodeToString(pub) + "\n"), false},
+		{"PEM", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), false},
+		{"truncated raw", []byte(pub[:31]), true},
+		{"truncated base64", []byte(base64.StdEncoding.EncodeToString(pub[:16])), true},
+		{"corrupt PEM", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der[:10]}), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := ParsePublicKey(tt.data)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !key.Equal(pub) {
+				t.Error("parsed key differs from the generated one")
+			}
+		})
+	}
+}